@@ -0,0 +1,295 @@
+package dap
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/reloonfire/rof-language/rof"
+)
+
+// errTerminated unwinds the interpreter when the client stops the program
+var errTerminated = errors.New("terminated by the debugger")
+
+type stepMode int
+
+const (
+	stepNone stepMode = iota
+	stepIn
+	stepOver
+	stepOut
+	stepTerminate
+)
+
+// frame - The script, or a block expression being evaluated. The line,
+// environment and interpreter are the ones of the last statement run in
+// the frame.
+type frame struct {
+	name        string
+	line        int
+	env         *rof.Environment
+	interpreter rof.Interpreter
+}
+
+type breakpoint struct {
+	condition rof.Expr
+}
+
+// debugger implements rof.Debugger. The interpreter runs in its own
+// goroutine and blocks in Statement while the program is stopped, the
+// server answers the inspection requests from the state saved here.
+type debugger struct {
+	server *Server
+	resume chan stepMode
+
+	mu          sync.Mutex
+	breakpoints map[int]breakpoint
+	frames      []*frame
+	pause       bool
+	entry       bool
+	terminate   bool
+	step        stepMode
+	stepDepth   int
+	stopped     bool
+	// stoppedAt is the span of the statement the program last stopped
+	// at, the statements nested in it on the same line do not stop again
+	stoppedAt rof.Span
+	// refs holds the scopes and records shown while stopped, a
+	// variablesReference is an index in refs plus one
+	refs []interface{}
+}
+
+func newDebugger(server *Server) *debugger {
+	return &debugger{
+		server:      server,
+		resume:      make(chan stepMode),
+		breakpoints: map[int]breakpoint{},
+		frames:      []*frame{{name: "script"}},
+	}
+}
+
+// Statement stops before stmt when a breakpoint, a step or a pause asks
+// for it. A block only stops for a pause, its statements stop for the rest.
+func (d *debugger) Statement(i rof.Interpreter, stmt rof.Stmt) {
+	_, block := stmt.(rof.Block)
+	line := rof.StmtLine(stmt)
+	span := rof.StmtSpan(stmt)
+
+	d.mu.Lock()
+	if d.terminate {
+		d.mu.Unlock()
+		panic(errTerminated)
+	}
+	top := d.frames[len(d.frames)-1]
+	top.env, top.interpreter = i.Env, i
+	if line != 0 {
+		top.line = line
+	}
+	nested := nestedIn(span, d.stoppedAt)
+	if !nested {
+		d.stoppedAt = rof.Span{}
+	}
+	reason := ""
+	switch {
+	case line == 0:
+	case d.entry:
+		d.entry, reason = false, "entry"
+	case d.pause:
+		d.pause, reason = false, "pause"
+	case block:
+	case d.step == stepIn,
+		d.step == stepOver && len(d.frames) <= d.stepDepth,
+		d.step == stepOut && len(d.frames) < d.stepDepth:
+		reason = "step"
+	default:
+		if bp, ok := d.breakpoints[line]; ok && !nested && d.hit(i, bp) {
+			reason = "breakpoint"
+		}
+	}
+	if reason != "" {
+		d.stoppedAt = span
+	}
+	d.mu.Unlock()
+
+	if reason != "" {
+		d.stop(reason)
+	}
+}
+
+// nestedIn tells whether span is strictly inside outer and starts on
+// the same line, running the same statement again is not nested
+func nestedIn(span, outer rof.Span) bool {
+	if span.IsZero() || outer.IsZero() || span == outer {
+		return false
+	}
+	return span.Start.Line == outer.Start.Line &&
+		span.Start.Offset >= outer.Start.Offset && span.End.Offset <= outer.End.Offset
+}
+
+// Enter pushes the frame of a block expression. Like Statement it
+// honors a pause or a terminate, the body of a loop expression may run
+// no statement at all.
+func (d *debugger) Enter(i rof.Interpreter, expr rof.Compound) {
+	d.mu.Lock()
+	name := "block expression"
+	if line := rof.ExprLine(expr); line != 0 {
+		name += " at line " + strconv.Itoa(line)
+	}
+	d.frames = append(d.frames, &frame{name: name, line: rof.ExprLine(expr), env: i.Env, interpreter: i})
+	terminate, pause := d.terminate, d.pause && len(expr.Statements) == 0
+	if pause {
+		d.pause, d.stoppedAt = false, rof.ExprSpan(expr)
+	}
+	d.mu.Unlock()
+
+	if terminate {
+		panic(errTerminated)
+	}
+	if pause {
+		d.stop("pause")
+	}
+}
+
+func (d *debugger) Leave(i rof.Interpreter, expr rof.Compound) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.frames = d.frames[:len(d.frames)-1]
+}
+
+// hit evaluates the condition of a breakpoint, a condition failing with
+// an error stops the program so the user sees it is wrong
+func (d *debugger) hit(i rof.Interpreter, bp breakpoint) bool {
+	if bp.condition == nil {
+		return true
+	}
+	i.Debugger = nil
+	value, err := i.Evaluate(bp.condition)
+	return err != nil || value != nil && value != false
+}
+
+// stop blocks the interpreter until the client resumes it
+func (d *debugger) stop(reason string) {
+	d.mu.Lock()
+	d.stopped, d.step, d.refs = true, stepNone, nil
+	d.mu.Unlock()
+
+	d.server.event("stopped", map[string]interface{}{"reason": reason, "threadId": 1, "allThreadsStopped": true})
+	mode := <-d.resume
+
+	d.mu.Lock()
+	d.stopped, d.step, d.stepDepth = false, mode, len(d.frames)
+	d.mu.Unlock()
+	if mode == stepTerminate {
+		panic(errTerminated)
+	}
+}
+
+// resumeWith restarts the program if it is stopped
+func (d *debugger) resumeWith(mode stepMode) {
+	d.mu.Lock()
+	stopped := d.stopped
+	d.mu.Unlock()
+	if stopped {
+		d.resume <- mode
+	}
+}
+
+// Inspection, only valid while stopped
+
+func (d *debugger) stackTrace(source Source) []StackFrame {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	frames := []StackFrame{}
+	for n := len(d.frames) - 1; n >= 0; n-- {
+		f := d.frames[n]
+		frames = append(frames, StackFrame{ID: n + 1, Name: f.name, Source: source, Line: f.line, Column: 1})
+	}
+	return frames
+}
+
+func (d *debugger) frame(id int) *frame {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.stopped || id < 1 || id > len(d.frames) {
+		return nil
+	}
+	return d.frames[id-1]
+}
+
+// scopes walks the environment chain of a frame from the innermost scope
+func (d *debugger) scopes(id int) []Scope {
+	scopes := []Scope{}
+	f := d.frame(id)
+	if f == nil {
+		return scopes
+	}
+	depth := 0
+	for env := f.env; env != nil; env = env.Enclosing {
+		name := "Block"
+		switch {
+		case env.Enclosing == nil:
+			name = "Globals"
+		case depth == 0:
+			name = "Locals"
+		}
+		scopes = append(scopes, Scope{Name: name, VariablesReference: d.ref(env)})
+		depth++
+	}
+	return scopes
+}
+
+func (d *debugger) variables(ref int) []Variable {
+	d.mu.Lock()
+	if ref < 1 || ref > len(d.refs) {
+		d.mu.Unlock()
+		return []Variable{}
+	}
+	object := d.refs[ref-1]
+	d.mu.Unlock()
+
+	variables := []Variable{}
+	switch t := object.(type) {
+	case *rof.Environment:
+		for _, name := range t.Names() {
+			value := t.Values[name]
+			// Builtins would drown the globals of the script.
+			if _, ok := value.(rof.NativeFunction); ok && t.Enclosing == nil {
+				continue
+			}
+			variables = append(variables, d.variable(name, value))
+		}
+	case *rof.RecordInstance:
+		for n, field := range t.Type.Fields {
+			variables = append(variables, d.variable(field, t.Values[n]))
+		}
+	}
+	return variables
+}
+
+func (d *debugger) variable(name string, value interface{}) Variable {
+	v := Variable{Name: name, Value: display(value), Type: rof.TypeOf(value)}
+	if instance, ok := value.(*rof.RecordInstance); ok {
+		v.VariablesReference = d.ref(instance)
+	}
+	return v
+}
+
+// ref returns the variablesReference of a scope or record
+func (d *debugger) ref(object interface{}) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for n, r := range d.refs {
+		if r == object {
+			return n + 1
+		}
+	}
+	d.refs = append(d.refs, object)
+	return len(d.refs)
+}
+
+func display(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return rof.Stringify(value)
+}
@@ -0,0 +1,523 @@
+// Package dap implements a Debug Adapter Protocol server for Rof scripts
+// over stdin and stdout.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/reloonfire/rof-language/helpers"
+	"github.com/reloonfire/rof-language/rof"
+)
+
+// Server - Debug adapter running one program. The program starts once
+// both 'launch' and 'configurationDone' were received.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+	// mu guards seq and the writes, events come from the program too
+	mu  sync.Mutex
+	seq int
+
+	debugger   *debugger
+	source     Source
+	stmts      []rof.Stmt
+	lines      map[int]bool
+	args       []string
+	launched   bool
+	configured bool
+	running    bool
+	// pending holds the breakpoints set before the launch, by path,
+	// they are verified by a 'breakpoint' event once it is loaded
+	pending map[string]pendingBreakpoints
+	// breakpointID is the id of the last breakpoint returned
+	breakpointID int
+}
+
+type pendingBreakpoints struct {
+	requested []sourceBreakpoint
+	ids       []int
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	s := &Server{in: bufio.NewReader(in), out: out, pending: map[string]pendingBreakpoints{}}
+	s.debugger = newDebugger(s)
+	return s
+}
+
+// Run - Serve requests until the client disconnects
+func (s *Server) Run() error {
+	for {
+		body, err := helpers.ReadMessage(s.in)
+		if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return err
+		}
+		result, err := s.handle(req)
+		if err != nil {
+			s.respond(req, false, err.Error(), nil)
+			continue
+		}
+		s.respond(req, true, "", result)
+
+		// Anything that makes the program send events is done after the
+		// response, so the client sees them in order.
+		switch req.Command {
+		case "initialize":
+			s.event("initialized", nil)
+		case "launch", "configurationDone":
+			s.start()
+		case "continue", "next", "stepIn", "stepOut", "terminate", "disconnect":
+			s.debugger.resumeWith(resumeModes[req.Command])
+		}
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+var resumeModes = map[string]stepMode{
+	"continue":   stepNone,
+	"next":       stepOver,
+	"stepIn":     stepIn,
+	"stepOut":    stepOut,
+	"terminate":  stepTerminate,
+	"disconnect": stepTerminate,
+}
+
+func (s *Server) handle(req request) (interface{}, error) {
+	d := s.debugger
+	switch req.Command {
+	case "initialize":
+		return map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsConditionalBreakpoints":   true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		var args launchArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		if err := s.load(args); err != nil {
+			return nil, err
+		}
+		s.launched = true
+		return nil, nil
+	case "configurationDone":
+		s.configured = true
+		return nil, nil
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"breakpoints": s.setBreakpoints(args.Source.Path, args.Breakpoints)}, nil
+	case "threads":
+		return map[string]interface{}{"threads": []Thread{{1, "main"}}}, nil
+	case "stackTrace":
+		frames := d.stackTrace(s.source)
+		return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+	case "scopes":
+		var args frameArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"scopes": d.scopes(args.FrameID)}, nil
+	case "variables":
+		var args variablesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"variables": d.variables(args.VariablesReference)}, nil
+	case "evaluate":
+		var args evaluateArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.evaluate(args)
+	case "continue", "next", "stepIn", "stepOut":
+		d.mu.Lock()
+		stopped := d.stopped
+		d.mu.Unlock()
+		if !stopped {
+			return nil, errors.New("the program is not stopped")
+		}
+		if req.Command == "continue" {
+			return map[string]interface{}{"allThreadsContinued": true}, nil
+		}
+		return nil, nil
+	case "pause":
+		d.mu.Lock()
+		d.pause = true
+		d.mu.Unlock()
+		return nil, nil
+	case "terminate", "disconnect":
+		// A running program stops at its next statement.
+		d.mu.Lock()
+		d.terminate = true
+		d.mu.Unlock()
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported request %q", req.Command)
+}
+
+// load reads and parses the program, syntax errors fail the launch
+func (s *Server) load(args launchArguments) error {
+	path, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	sc := rof.NewScanner(string(b))
+	tokens := sc.Scan()
+	if sc.HadError {
+		start := sc.Errors[0].Span.Start
+		return fmt.Errorf("%s:%d:%d: %s", args.Program, start.Line, start.Column, sc.Errors[0].Message)
+	}
+	parser := rof.Parser{File: path, Tokens: tokens, Quiet: true}
+	stmts, errs := parser.Parse()
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %v", args.Program, errs[0])
+	}
+	s.stmts = stmts
+
+	s.source = Source{Name: filepath.Base(path), Path: path}
+	s.args = args.Args
+	s.lines = map[int]bool{}
+	statementLines(s.stmts, s.lines)
+	s.debugger.entry = args.StopOnEntry
+	if p, ok := s.pending[path]; ok {
+		delete(s.pending, path)
+		for _, b := range s.verifyBreakpoints(p.requested, p.ids) {
+			s.event("breakpoint", map[string]interface{}{"reason": "changed", "breakpoint": b})
+		}
+	}
+	return nil
+}
+
+func (s *Server) start() {
+	if !s.launched || !s.configured || s.running {
+		return
+	}
+	s.running = true
+
+	go func() {
+		i := rof.NewInterpreter()
+		i.DefineArgs(s.args)
+		i.Out = outputWriter{s, "stdout"}
+		i.Debugger = s.debugger
+		code := 0
+		if err := i.Interpret(s.stmts); err != nil && err != errTerminated {
+			s.event("output", map[string]interface{}{"category": "stderr", "output": "Runtime Error: " + err.Error() + "\n"})
+			code = 70
+		}
+		s.event("exited", map[string]interface{}{"exitCode": code})
+		s.event("terminated", nil)
+	}()
+}
+
+// setBreakpoints replaces the breakpoints of a file, they are verified
+// when there is a statement on their line and their condition parses
+func (s *Server) setBreakpoints(path string, requested []sourceBreakpoint) []Breakpoint {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	ids := make([]int, len(requested))
+	for n := range ids {
+		s.breakpointID++
+		ids[n] = s.breakpointID
+	}
+	if s.lines == nil || path != s.source.Path {
+		s.pending[path] = pendingBreakpoints{requested, ids}
+		result := []Breakpoint{}
+		for n, bp := range requested {
+			result = append(result, Breakpoint{ID: ids[n], Verified: false, Line: bp.Line, Message: "The program is not loaded."})
+		}
+		return result
+	}
+	return s.verifyBreakpoints(requested, ids)
+}
+
+// verifyBreakpoints replaces the breakpoints of the loaded program
+func (s *Server) verifyBreakpoints(requested []sourceBreakpoint, ids []int) []Breakpoint {
+	result := []Breakpoint{}
+	breakpoints := map[int]breakpoint{}
+	for n, bp := range requested {
+		b := Breakpoint{ID: ids[n], Line: bp.Line}
+		var condition rof.Expr
+		var err error
+		if bp.Condition != "" {
+			condition, err = parseExpression(bp.Condition)
+		}
+		switch {
+		case err != nil:
+			b.Message = "Invalid condition: " + err.Error()
+		case !s.lines[bp.Line]:
+			b.Message = "No statement on this line."
+		default:
+			b.Verified = true
+			breakpoints[bp.Line] = breakpoint{condition}
+		}
+		result = append(result, b)
+	}
+
+	s.debugger.mu.Lock()
+	s.debugger.breakpoints = breakpoints
+	s.debugger.mu.Unlock()
+	return result
+}
+
+// evaluate runs an expression in a frame of the stopped program, it can
+// change variables
+func (s *Server) evaluate(args evaluateArguments) (interface{}, error) {
+	f := s.debugger.frame(args.FrameID)
+	if f == nil {
+		return nil, errors.New("the program is not stopped")
+	}
+	expr, err := parseExpression(args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	i := f.interpreter
+	i.Debugger = nil
+	value, err := i.Evaluate(expr)
+	if err != nil {
+		return nil, err
+	}
+	v := s.debugger.variable("", value)
+	return map[string]interface{}{"result": v.Value, "type": v.Type, "variablesReference": v.VariablesReference}, nil
+}
+
+func (s *Server) respond(req request, success bool, message string, body interface{}) {
+	s.send(func(seq int) interface{} {
+		return response{seq, "response", req.Seq, success, req.Command, message, body}
+	})
+}
+
+func (s *Server) event(name string, body interface{}) {
+	s.send(func(seq int) interface{} {
+		return event{seq, "event", name, body}
+	})
+}
+
+func (s *Server) send(message func(seq int) interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	body, err := json.Marshal(message(s.seq))
+	if err != nil {
+		panic(err)
+	}
+	helpers.WriteMessage(s.out, body)
+}
+
+// outputWriter sends what the program prints as output events
+type outputWriter struct {
+	server   *Server
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.server.event("output", map[string]interface{}{"category": w.category, "output": string(p)})
+	return len(p), nil
+}
+
+func parseExpression(source string) (rof.Expr, error) {
+	sc := rof.NewScanner(source + ";")
+	tokens := sc.Scan()
+	if sc.HadError {
+		return nil, errors.New(sc.Errors[0].Message)
+	}
+	parser := rof.Parser{Tokens: tokens, Quiet: true}
+	stmts, errs := parser.Parse()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	if len(stmts) != 1 {
+		return nil, errors.New("expected a single expression")
+	}
+	stmt, ok := stmts[0].(rof.Expression)
+	if !ok {
+		return nil, errors.New("expected an expression")
+	}
+	return stmt.Expr, nil
+}
+
+// statementLines collects the lines where the debugger can stop
+func statementLines(stmts []rof.Stmt, lines map[int]bool) {
+	c := lineCollector{lines}
+	for _, stmt := range stmts {
+		c.stmt(stmt)
+	}
+}
+
+// lineCollector walks the tree for statementLines, block expressions
+// hold statements too
+type lineCollector struct {
+	lines map[int]bool
+}
+
+func (c lineCollector) stmt(stmt rof.Stmt) {
+	if stmt == nil {
+		return
+	}
+	if _, ok := stmt.(rof.Block); !ok {
+		if line := rof.StmtLine(stmt); line != 0 {
+			c.lines[line] = true
+		}
+	}
+	stmt.Accept(c)
+}
+
+func (c lineCollector) expr(expr rof.Expr) {
+	if expr != nil {
+		expr.Accept(c)
+	}
+}
+
+func (c lineCollector) VisitExpressionStmt(stmt rof.Expression) interface{} {
+	c.expr(stmt.Expr)
+	return nil
+}
+
+func (c lineCollector) VisitPrintStmt(stmt rof.Print) interface{} {
+	c.expr(stmt.Expr)
+	return nil
+}
+
+func (c lineCollector) VisitVarStmt(stmt rof.Var) interface{} {
+	c.expr(stmt.Initializer)
+	return nil
+}
+
+func (c lineCollector) VisitBlockStmt(stmt rof.Block) interface{} {
+	for _, s := range stmt.Statements {
+		c.stmt(s)
+	}
+	return nil
+}
+
+func (c lineCollector) VisitRecordStmt(stmt rof.Record) interface{} {
+	return nil
+}
+
+func (c lineCollector) VisitTestStmt(stmt rof.Test) interface{} {
+	for _, s := range stmt.Body {
+		c.stmt(s)
+	}
+	return nil
+}
+
+func (c lineCollector) VisitIfStmt(stmt rof.If) interface{} {
+	c.expr(stmt.Condition)
+	c.stmt(stmt.ThenBranch)
+	c.stmt(stmt.ElseBranch)
+	return nil
+}
+
+func (c lineCollector) VisitWhileStmt(stmt rof.While) interface{} {
+	c.expr(stmt.Condition)
+	c.stmt(stmt.Body)
+	return nil
+}
+
+func (c lineCollector) VisitEmptyStmt(stmt rof.Empty) interface{} {
+	return nil
+}
+
+func (c lineCollector) VisitAssignExpr(expr rof.Assign) interface{} {
+	c.expr(expr.Value)
+	return nil
+}
+
+func (c lineCollector) VisitBinaryExpr(expr rof.Binary) interface{} {
+	c.expr(expr.Left)
+	c.expr(expr.Right)
+	return nil
+}
+
+func (c lineCollector) VisitGroupingExpr(expr rof.Grouping) interface{} {
+	c.expr(expr.Expr)
+	return nil
+}
+
+func (c lineCollector) VisitLiteralExpr(expr rof.Literal) interface{} {
+	return nil
+}
+
+func (c lineCollector) VisitUnaryExpr(expr rof.Unary) interface{} {
+	c.expr(expr.Right)
+	return nil
+}
+
+func (c lineCollector) VisitVariableExpr(expr rof.Variable) interface{} {
+	return nil
+}
+
+func (c lineCollector) VisitLogicalExpr(expr rof.Logical) interface{} {
+	c.expr(expr.Left)
+	c.expr(expr.Right)
+	return nil
+}
+
+func (c lineCollector) VisitCallExpr(expr rof.Call) interface{} {
+	c.expr(expr.Callee)
+	for _, a := range expr.Args {
+		c.expr(a)
+	}
+	return nil
+}
+
+func (c lineCollector) VisitIsExpr(expr rof.Is) interface{} {
+	c.expr(expr.Value)
+	return nil
+}
+
+func (c lineCollector) VisitGetExpr(expr rof.Get) interface{} {
+	c.expr(expr.Object)
+	return nil
+}
+
+func (c lineCollector) VisitWithExpr(expr rof.With) interface{} {
+	c.expr(expr.Object)
+	for _, v := range expr.Values {
+		c.expr(v)
+	}
+	return nil
+}
+
+func (c lineCollector) VisitConditionalExpr(expr rof.Conditional) interface{} {
+	c.expr(expr.Condition)
+	c.expr(expr.ThenBranch)
+	c.expr(expr.ElseBranch)
+	return nil
+}
+
+func (c lineCollector) VisitCompoundExpr(expr rof.Compound) interface{} {
+	for _, s := range expr.Statements {
+		c.stmt(s)
+	}
+	c.expr(expr.Value)
+	return nil
+}
+
+func (c lineCollector) VisitLoopExpr(expr rof.Loop) interface{} {
+	c.expr(expr.Condition)
+	c.expr(expr.Body)
+	return nil
+}
@@ -0,0 +1,304 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/reloonfire/rof-language/helpers"
+	"github.com/reloonfire/rof-language/rof"
+)
+
+func TestNestedIn(t *testing.T) {
+	span := func(line, start, end int) rof.Span {
+		return rof.Span{
+			Start: rof.Position{Line: line, Column: start + 1, Offset: start},
+			End:   rof.Position{Line: line, Column: end + 1, Offset: end},
+		}
+	}
+	tests := []struct {
+		name        string
+		span, outer rof.Span
+		want        bool
+	}{
+		{"inside on the same line", span(1, 10, 20), span(1, 0, 30), true},
+		{"same statement again", span(1, 0, 30), span(1, 0, 30), false},
+		{"next line", span(2, 31, 40), span(1, 0, 30), false},
+		{"after on the same line", span(1, 31, 40), span(1, 0, 30), false},
+		{"not stopped", span(1, 10, 20), rof.Span{}, false},
+		{"no span", rof.Span{}, span(1, 0, 30), false},
+	}
+	for _, tt := range tests {
+		if got := nestedIn(tt.span, tt.outer); got != tt.want {
+			t.Errorf("%s: nestedIn = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStatementLines(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []int
+	}{
+		{"print 1;\n\nprint 2;", []int{1, 3}},
+		{"{\n  print 1;\n}", []int{2}},
+		{"if (true)\n  print 1;\nelse\n  print 2;", []int{1, 2, 4}},
+		{"while (false) {\n  print 1;\n}", []int{1, 2}},
+		{"var a = {\n  print 1;\n  2\n};", []int{1, 2}},
+		{"print f({\n  var b = 1;\n  b\n});", []int{1, 2}},
+		{"record P(x);\n;", []int{1, 2}},
+	}
+	for _, tt := range tests {
+		sc := rof.NewScanner(tt.source)
+		parser := rof.Parser{Tokens: sc.Scan(), Quiet: true}
+		stmts, errs := parser.Parse()
+		if len(errs) > 0 {
+			t.Errorf("%q: %v", tt.source, errs)
+			continue
+		}
+		lines := map[int]bool{}
+		statementLines(stmts, lines)
+		var got []int
+		for line := range lines {
+			got = append(got, line)
+		}
+		sort.Ints(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: lines %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+// client drives a server over pipes, it keeps the events received
+// while waiting for a response
+type client struct {
+	t        *testing.T
+	in       io.WriteCloser
+	messages chan map[string]interface{}
+	seq      int
+	events   []map[string]interface{}
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	go func() {
+		NewServer(inR, outW).Run()
+		outW.Close()
+	}()
+	// The server writes events while the client sends requests, the
+	// output is read all the time so that neither side blocks.
+	messages := make(chan map[string]interface{}, 100)
+	go func() {
+		defer close(messages)
+		out := bufio.NewReader(outR)
+		for {
+			body, err := helpers.ReadMessage(out)
+			if err != nil {
+				return
+			}
+			var message map[string]interface{}
+			if err := json.Unmarshal(body, &message); err != nil {
+				return
+			}
+			messages <- message
+		}
+	}()
+	return &client{t: t, in: inW, messages: messages}
+}
+
+// next returns the events kept first, then the next message
+func (c *client) next() map[string]interface{} {
+	c.t.Helper()
+	if len(c.events) > 0 {
+		message := c.events[0]
+		c.events = c.events[1:]
+		return message
+	}
+	select {
+	case message, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("the server stopped")
+		}
+		return message
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("no message from the server")
+	}
+	return nil
+}
+
+// request sends a request and returns the body of its response
+func (c *client) request(command string, arguments interface{}) interface{} {
+	c.t.Helper()
+	c.seq++
+	b, _ := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": arguments})
+	if err := helpers.WriteMessage(c.in, b); err != nil {
+		c.t.Fatal(err)
+	}
+	var events []map[string]interface{}
+	for {
+		message := c.next()
+		if message["type"] == "event" {
+			events = append(events, message)
+			continue
+		}
+		c.events = append(c.events, events...)
+		if message["success"] != true {
+			c.t.Fatalf("%s failed: %v", command, message["message"])
+		}
+		return message["body"]
+	}
+}
+
+// event returns the next event with a name, skipping the others
+func (c *client) event(name string) map[string]interface{} {
+	c.t.Helper()
+	for {
+		if message := c.next(); message["event"] == name {
+			return message
+		}
+	}
+}
+
+func writeProgram(t *testing.T, source string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "dap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "program.rof")
+	if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func breakpoints(body interface{}) []interface{} {
+	return body.(map[string]interface{})["breakpoints"].([]interface{})
+}
+
+func TestBreakpointVerification(t *testing.T) {
+	path := writeProgram(t, "var i = 0;\n\nwhile (i < 3) {\n  i = i + 1;\n}\n")
+	requested := []map[string]interface{}{
+		{"line": 4},
+		{"line": 2},
+		{"line": 4, "condition": "i =="},
+	}
+	tests := []struct {
+		line     float64
+		verified bool
+		message  string
+	}{
+		{4, true, ""},
+		{2, false, "No statement on this line."},
+		{4, false, "Invalid condition: line #1 at ';': Expect expression"},
+	}
+	check := func(when string, got []interface{}) {
+		t.Helper()
+		for n, tt := range tests {
+			b := got[n].(map[string]interface{})
+			message, _ := b["message"].(string)
+			if b["line"] != tt.line || b["verified"] != tt.verified || message != tt.message {
+				t.Errorf("%s: breakpoint %v, want line %v verified %v %q", when, b, tt.line, tt.verified, tt.message)
+			}
+		}
+	}
+
+	// Set before the launch, they are verified by events.
+	c := newClient(t)
+	c.request("initialize", nil)
+	body := c.request("setBreakpoints", map[string]interface{}{"source": map[string]string{"path": path}, "breakpoints": requested})
+	for n, b := range breakpoints(body) {
+		if b.(map[string]interface{})["verified"] != false {
+			t.Errorf("breakpoint %d is verified before the launch", n)
+		}
+	}
+	c.request("launch", map[string]interface{}{"program": path})
+	var events []interface{}
+	for range requested {
+		events = append(events, c.event("breakpoint")["body"].(map[string]interface{})["breakpoint"])
+	}
+	check("before the launch", events)
+	c.request("disconnect", nil)
+
+	// Set after the launch, they are verified in the response.
+	c = newClient(t)
+	c.request("initialize", nil)
+	c.request("launch", map[string]interface{}{"program": path})
+	check("after the launch", breakpoints(c.request("setBreakpoints", map[string]interface{}{"source": map[string]string{"path": path}, "breakpoints": requested})))
+	c.request("disconnect", nil)
+}
+
+func TestBreakpointStops(t *testing.T) {
+	path := writeProgram(t, "var i = 0;\nwhile (i < 3) {\n  i = i + 1;\n}\nvar j = { while (i < 6) i = i + 1; i };\n")
+	tests := []struct {
+		line      int
+		condition string
+		stops     int
+	}{
+		{3, "", 3},
+		{3, "i == 2", 1},
+		{3, "i > 5", 0},
+		{1, "", 1},
+		{5, "", 1},
+	}
+	for _, tt := range tests {
+		c := newClient(t)
+		c.request("initialize", nil)
+		c.request("setBreakpoints", map[string]interface{}{
+			"source":      map[string]string{"path": path},
+			"breakpoints": []map[string]interface{}{{"line": tt.line, "condition": tt.condition}},
+		})
+		c.request("launch", map[string]interface{}{"program": path})
+		c.request("configurationDone", nil)
+		stops := 0
+		for {
+			message := c.next()
+			if message["event"] == "terminated" {
+				break
+			}
+			if message["event"] == "stopped" {
+				stops++
+				c.request("continue", map[string]interface{}{"threadId": 1})
+			}
+		}
+		if stops != tt.stops {
+			t.Errorf("line %d %q: stopped %d times, want %d", tt.line, tt.condition, stops, tt.stops)
+		}
+		c.request("disconnect", nil)
+	}
+}
+
+func TestPauseAndTerminateLoop(t *testing.T) {
+	programs := []string{
+		"while (true) {}\n",
+		"while (true);\n",
+		"var x = { while (true) {} };\n",
+	}
+	for _, source := range programs {
+		path := writeProgram(t, source)
+		c := newClient(t)
+		c.request("initialize", nil)
+		c.request("launch", map[string]interface{}{"program": path})
+		c.request("configurationDone", nil)
+
+		c.request("pause", map[string]interface{}{"threadId": 1})
+		if reason := c.event("stopped")["body"].(map[string]interface{})["reason"]; reason != "pause" {
+			t.Errorf("%q: stopped for %v, want pause", source, reason)
+		}
+		c.request("continue", map[string]interface{}{"threadId": 1})
+
+		// A running loop ends at its next turn.
+		c.request("terminate", nil)
+		c.event("terminated")
+		c.request("disconnect", nil)
+	}
+}
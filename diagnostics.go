@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/reloonfire/rof-language/helpers"
+	"github.com/reloonfire/rof-language/rof"
+)
+
+// diagnosticsFormat is set by the -diagnostics-format flag. text
+// renders every diagnostic with its source as it comes, json and sarif
+// collect them and write one document to stderr when the command ends.
+var diagnosticsFormat = "text"
+
+// diagnostics are the diagnostics collected for json and sarif, sources
+// the content of their files by name
+var (
+	diagnostics []rof.Diagnostic
+	sources     = map[string]string{}
+)
+
+// warningOptions are set by the -W flags
+var warningOptions rof.WarningOptions
+
+// addDiagnosticsFlag adds -diagnostics-format to the flags of a command,
+// validDiagnosticsFormat checks it once they are parsed
+func addDiagnosticsFlag(flags *flag.FlagSet) {
+	flags.StringVar(&diagnosticsFormat, "diagnostics-format", "text", "write the errors and warnings as `format`, text, json or sarif")
+}
+
+// addWarningsFlag adds -W to the flags of a command parsing scripts
+func addWarningsFlag(flags *flag.FlagSet) {
+	flags.Var(&warningOptions, "W", "enable the `warning`, no-warning disables it, all and none set every warning, error and error=warning turn warnings into errors")
+}
+
+func validDiagnosticsFormat() bool {
+	switch diagnosticsFormat {
+	case "text", "json", "sarif":
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "rof: unknown diagnostics format %q\n", diagnosticsFormat)
+	return false
+}
+
+// warningsReported counts the warnings passed to report
+var warningsReported int
+
+// report renders or collects a diagnostic of the script name
+func report(name, source string, d rof.Diagnostic) {
+	d = d.InFile(name)
+	if d.Severity == rof.SeverityWarning {
+		warningsReported++
+	}
+	if diagnosticsFormat == "text" {
+		rof.NewRenderer(os.Stderr, name, source, helpers.IsTerminal(os.Stderr)).Render(d)
+		return
+	}
+	diagnostics = append(diagnostics, d)
+	sources[name] = source
+}
+
+// reportErrors reports every error of an ErrorList, or err alone
+func reportErrors(name, source string, err error) {
+	errs, ok := err.(rof.ErrorList)
+	if !ok {
+		errs = rof.ErrorList{err}
+	}
+	for _, err := range errs {
+		report(name, source, rof.DiagnosticOf(err))
+	}
+}
+
+// writeDiagnostics writes the collected diagnostics, even when there are
+// none so that tools always get a document
+func writeDiagnostics(w io.Writer) error {
+	var b []byte
+	var err error
+	switch diagnosticsFormat {
+	case "json":
+		b, err = rof.EncodeDiagnosticsJSON(diagnostics)
+	case "sarif":
+		b, err = rof.EncodeSARIF(diagnostics, version, sources)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/reloonfire/rof-language/helpers"
+	"github.com/reloonfire/rof-language/rof"
+)
+
+// exitUnformatted is returned by 'fmt -check' and 'fmt -diff' when a
+// file is not formatted
+const exitUnformatted = 1
+
+func fmtCmd(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false, "list the files that are not formatted and fail")
+	diff := flags.Bool("diff", false, "print the changes formatting would make and fail if any")
+	write := flags.Bool("w", false, "write the result to the file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	code := exitOK
+	for _, name := range files {
+		var b []byte
+		var err error
+		if name == "-" {
+			b, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			b, err = ioutil.ReadFile(name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rof:", err)
+			return exitIOError
+		}
+		source := string(b)
+		formatted, err := rof.Format(source)
+		if err != nil {
+			reportErrors(name, source, err)
+			code = syntaxErrorCode(err)
+			continue
+		}
+
+		switch {
+		case *check:
+			if formatted != source {
+				fmt.Println(name)
+				code = maxCode(code, exitUnformatted)
+			}
+		case *diff:
+			if d := helpers.UnifiedDiff(name, name+" (formatted)", source, formatted); d != "" {
+				fmt.Print(d)
+				code = maxCode(code, exitUnformatted)
+			}
+		case *write && name != "-":
+			if formatted != source {
+				if err := ioutil.WriteFile(name, []byte(formatted), 0644); err != nil {
+					fmt.Fprintln(os.Stderr, "rof:", err)
+					return exitIOError
+				}
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+	return code
+}
+
+// syntaxErrorCode returns the exit code of a script that does not scan
+// or parse
+func syntaxErrorCode(err error) int {
+	if errs, ok := err.(rof.ErrorList); ok {
+		err = errs[0]
+	}
+	if _, ok := err.(*rof.ScanError); ok {
+		return exitScanError
+	}
+	return exitParseError
+}
+
+func maxCode(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -1,69 +1,258 @@
 package main
 
-/*
 import (
-	"bufio"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"unicode"
 
-	"github.com/reloonfire/rof-language/helpers"
+	"github.com/reloonfire/rof-language/lineedit"
 	"github.com/reloonfire/rof-language/rof"
 )
 
-var (
-	hadError = false
-)
+const replHelp = `Enter statements to run them, the value of a trailing expression is printed.
+Input continues on the next line while braces or parentheses are open.
+Tab completes keywords and names, Ctrl-R searches the history.
+
+  :load <file>  run a file in the current session
+  :env          list the variables defined so far
+  :reset        forget every definition
+  :quit         leave the shell
+  :help         show this message
+`
+
+// lineReader reads one line of input, without the trailing newline
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+type session struct {
+	interpreter rof.Interpreter
+	input       lineReader
+}
+
+func newSession(input lineReader) *session {
+	s := &session{input: input}
+	s.reset()
+	return s
+}
+
+// repl runs the interactive shell, definitions persist between inputs
+func repl() int {
+	history := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		history = filepath.Join(home, ".rof_history")
+	}
+	editor := lineedit.New(history)
+	s := newSession(editor)
+	editor.Complete = s.complete
+	fmt.Printf("rof %s, type :help for help\n", version)
+	for {
+		source, err := s.read()
+		if err != nil {
+			fmt.Println()
+			return exitOK
+		}
+		if strings.HasPrefix(strings.TrimSpace(source), ":") {
+			if !s.command(strings.TrimSpace(source)) {
+				return exitOK
+			}
+			continue
+		}
+		s.eval(source)
+	}
+}
 
-func pnc(err error) {
+// read returns the next complete input, asking for more lines while
+// the source has open braces, parentheses, strings or comments.
+// Ctrl-C discards the input read so far.
+func (s *session) read() (string, error) {
+	line, err := s.input.ReadLine("> ")
+	if err == lineedit.ErrInterrupted {
+		return "", nil
+	}
 	if err != nil {
-		panic(err)
+		return "", err
+	}
+	source := line
+	for unbalanced(source) {
+		line, err = s.input.ReadLine("... ")
+		if err == lineedit.ErrInterrupted {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		source += "\n" + line
+	}
+	return source, nil
+}
+
+// command runs a meta-command, it returns false to leave the shell
+func (s *session) command(line string) bool {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case ":quit", ":q", ":exit":
+		return false
+	case ":reset":
+		s.reset()
+	case ":env":
+		s.env()
+	case ":load":
+		if len(fields) != 2 {
+			fmt.Println("Usage: :load <file>")
+			break
+		}
+		b, err := ioutil.ReadFile(fields[1])
+		if err != nil {
+			fmt.Println("rof:", err)
+			break
+		}
+		s.eval(string(b))
+	case ":help":
+		fmt.Print(replHelp)
+	default:
+		fmt.Printf("Unknown command %s, type :help for help\n", fields[0])
 	}
-	return
+	return true
 }
 
-func main() {
-	if len(os.Args) > 1 {
-		// file passato inizio con l'interpretazione
-		// gRof()
-		sc := new(rof.Scanner)
-		parser := new(rof.Parser)
-		s, _ := ioutil.ReadFile("test.rof")
-		sc.Source = string(s)
-		tokens := sc.Scan()
-		if sc.HadError {
+func (s *session) eval(source string) {
+	stmts, code := parse("<repl>", source)
+	if code != exitOK || len(stmts) == 0 {
+		return
+	}
+
+	last, echo := stmts[len(stmts)-1].(rof.Expression)
+	if echo {
+		stmts = stmts[:len(stmts)-1]
+	}
+	if err := s.interpreter.Interpret(stmts); err != nil {
+		report("<repl>", source, rof.DiagnosticOf(err))
+		return
+	}
+	if echo {
+		value, err := s.interpreter.Evaluate(last.Expr)
+		if err != nil {
+			report("<repl>", source, rof.DiagnosticOf(err))
 			return
 		}
-		fmt.Println("[DEBUG] Tokens -> ", tokens)
-		parser.Tokens = tokens
-		expr := parser.Parse()
-		fmt.Println(expr)
+		fmt.Println(rof.Stringify(value))
+	}
+}
+
+func (s *session) env() {
+	names := []string{}
+	values := map[string]interface{}{}
+	for e := s.interpreter.Env; e != nil; e = e.Enclosing {
+		for name, value := range e.Values {
+			if _, native := value.(rof.NativeFunction); native {
+				continue
+			}
+			if _, shadowed := values[name]; !shadowed {
+				names = append(names, name)
+				values[name] = value
+			}
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s = %s\n", name, rof.Stringify(values[name]))
+	}
+}
+
+// complete returns the keywords, meta-commands and defined names
+// starting with the word before the cursor
+func (s *session) complete(line []rune, pos int) (int, []string) {
+	start := pos
+	for start > 0 && (line[start-1] == '_' || unicode.IsLetter(line[start-1]) || unicode.IsDigit(line[start-1])) {
+		start--
+	}
+	word := string(line[start:pos])
 
-		//fmt.Println("Args: ", os.Args[1:])
+	var words []string
+	if start == 1 && line[0] == ':' {
+		start = 0
+		word = ":" + word
+		words = []string{":env", ":help", ":load", ":quit", ":reset"}
 	} else {
-		// Interactive mode
-		for {
-			hadError = false
-			reader := bufio.NewReader(os.Stdin)
-			fmt.Print("> ")
-			cmd, err := reader.ReadString('\n')
-			fmt.Println("[DEBUG] cmd = ", cmd)
-			pnc(err)
-			switch strings.Split(cmd, " ")[0] {
-			case "quit":
-				fmt.Println("\nGoodBye!")
-				return
-			case "run ":
-				path := strings.Split(cmd, " ")[1]
-
-				if path == "" {
-					helpers.ReportError(1, "cannot find path to executable file")
-					break
-				}
-				fmt.Println("Path to file = ", path)
+		words = rof.Keywords()
+		for e := s.interpreter.Env; e != nil; e = e.Enclosing {
+			words = append(words, e.Names()...)
+		}
+	}
+
+	seen := map[string]bool{}
+	candidates := []string{}
+	for _, w := range words {
+		if strings.HasPrefix(w, word) && !seen[w] {
+			seen[w] = true
+			candidates = append(candidates, w)
+		}
+	}
+	sort.Strings(candidates)
+	return start, candidates
+}
+
+func (s *session) reset() {
+	s.interpreter = rof.NewInterpreter()
+	s.interpreter.DefineArgs(nil)
+}
+
+// commentEnd returns the offset just past the block comment starting at
+// start, or -1 when it is not closed. Comments nest as in the scanner.
+func commentEnd(source string, start int) int {
+	depth := 0
+	for n := start; n < len(source); {
+		switch {
+		case strings.HasPrefix(source[n:], "/*"):
+			depth++
+			n += 2
+		case strings.HasPrefix(source[n:], "*/"):
+			depth--
+			n += 2
+			if depth == 0 {
+				return n
+			}
+		default:
+			n++
+		}
+	}
+	return -1
+}
+
+// unbalanced reports whether source ends inside a string, a block
+// comment or with more open than closed braces and parentheses
+func unbalanced(source string) bool {
+	depth := 0
+	for n := 0; n < len(source); n++ {
+		switch {
+		case source[n] == '"':
+			end := strings.IndexByte(source[n+1:], '"')
+			if end == -1 {
+				return true
+			}
+			n += end + 1
+		case strings.HasPrefix(source[n:], "//"):
+			end := strings.IndexByte(source[n:], '\n')
+			if end == -1 {
+				return depth > 0
+			}
+			n += end
+		case strings.HasPrefix(source[n:], "/*"):
+			end := commentEnd(source, n)
+			if end == -1 {
+				return true
 			}
+			n = end - 1
+		case source[n] == '(' || source[n] == '{':
+			depth++
+		case source[n] == ')' || source[n] == '}':
+			depth--
 		}
 	}
+	return depth > 0
 }
-*/
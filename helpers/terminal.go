@@ -0,0 +1,13 @@
+package helpers
+
+import "os"
+
+// IsTerminal - Tell whether f is a terminal, so output can be coloured.
+// Setting the NO_COLOR environment variable turns colour off.
+func IsTerminal(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
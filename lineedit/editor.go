@@ -0,0 +1,471 @@
+// Package lineedit reads lines from a terminal with editing, history
+// and completion, falling back to plain reads when stdin is not a TTY.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ErrInterrupted is returned by ReadLine when the user presses Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// MaxHistory is the number of entries kept in memory and on disk
+const MaxHistory = 1000
+
+// Completer returns the candidates for the word ending at pos and the
+// index where that word starts
+type Completer func(line []rune, pos int) (start int, candidates []string)
+
+// Editor - Line editor bound to stdin and stdout
+type Editor struct {
+	Complete    Completer
+	HistoryFile string
+	history     []string
+	in          *bufio.Reader
+	out         io.Writer
+	fd          int
+}
+
+type line struct {
+	prompt string
+	buf    []rune
+	pos    int
+}
+
+// Keys are the control characters and the escape sequences, mapped
+// past the last valid rune
+const (
+	ctrlA     = 1
+	ctrlB     = 2
+	ctrlC     = 3
+	ctrlD     = 4
+	ctrlE     = 5
+	ctrlF     = 6
+	ctrlG     = 7
+	ctrlH     = 8
+	tab       = 9
+	ctrlK     = 11
+	ctrlL     = 12
+	enter     = 13
+	ctrlN     = 14
+	ctrlP     = 16
+	ctrlR     = 18
+	ctrlU     = 21
+	ctrlW     = 23
+	esc       = 27
+	backspace = 127
+
+	keyUp rune = unicode.MaxRune + 1 + iota
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft
+	keyWordRight
+	keyDeleteWordLeft
+	keyDeleteWordRight
+	keyUnknown
+)
+
+// New returns an editor reading stdin, the history is loaded from
+// historyFile when it is not empty
+func New(historyFile string) *Editor {
+	e := &Editor{
+		HistoryFile: historyFile,
+		in:          bufio.NewReader(os.Stdin),
+		out:         os.Stdout,
+		fd:          int(os.Stdin.Fd()),
+	}
+	e.loadHistory()
+	return e
+}
+
+// ReadLine shows prompt and returns the line entered without the
+// trailing newline, io.EOF is returned on Ctrl-D on an empty line
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if !isTerminal(e.fd) {
+		return e.readPlain(prompt)
+	}
+	state, err := makeRaw(e.fd)
+	if err != nil {
+		return e.readPlain(prompt)
+	}
+	defer restore(e.fd, state)
+
+	l := &line{prompt: prompt}
+	index := len(e.history)
+	saved := ""
+	e.refresh(l)
+	for {
+		r, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+		if r == ctrlR {
+			if r, err = e.reverseSearch(l); err != nil {
+				return "", err
+			}
+			e.refresh(l)
+			if r == 0 {
+				continue
+			}
+		}
+
+		switch r {
+		case enter, '\n':
+			fmt.Fprint(e.out, "\r\n")
+			s := string(l.buf)
+			e.addHistory(s)
+			return s, nil
+		case ctrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case ctrlD:
+			if len(l.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			l.delete(l.pos, l.pos+1)
+		case backspace, ctrlH:
+			l.delete(l.pos-1, l.pos)
+		case keyDelete:
+			l.delete(l.pos, l.pos+1)
+		case ctrlA, keyHome:
+			l.pos = 0
+		case ctrlE, keyEnd:
+			l.pos = len(l.buf)
+		case ctrlB, keyLeft:
+			if l.pos > 0 {
+				l.pos--
+			}
+		case ctrlF, keyRight:
+			if l.pos < len(l.buf) {
+				l.pos++
+			}
+		case keyWordLeft:
+			l.pos = l.wordStart()
+		case keyWordRight:
+			l.pos = l.wordEnd()
+		case ctrlW, keyDeleteWordLeft:
+			l.delete(l.wordStart(), l.pos)
+		case keyDeleteWordRight:
+			l.delete(l.pos, l.wordEnd())
+		case ctrlK:
+			l.delete(l.pos, len(l.buf))
+		case ctrlU:
+			l.delete(0, l.pos)
+		case ctrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case ctrlP, keyUp, ctrlN, keyDown:
+			if index == len(e.history) {
+				saved = string(l.buf)
+			}
+			if r == ctrlP || r == keyUp {
+				if index == 0 {
+					continue
+				}
+				index--
+			} else {
+				if index == len(e.history) {
+					continue
+				}
+				index++
+			}
+			if index == len(e.history) {
+				l.set(saved)
+			} else {
+				l.set(e.history[index])
+			}
+		case tab:
+			e.complete(l)
+		default:
+			if r >= 32 && r <= unicode.MaxRune {
+				l.insert(r)
+			}
+		}
+		e.refresh(l)
+	}
+}
+
+func (e *Editor) readPlain(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	s, err := e.in.ReadString('\n')
+	if err == io.EOF && s != "" {
+		err = nil
+	}
+	return strings.TrimRight(s, "\r\n"), err
+}
+
+// readKey reads a rune, decoding escape sequences into the key constants
+func (e *Editor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != esc {
+		return r, err
+	}
+
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch r {
+	case 'b':
+		return keyWordLeft, nil
+	case 'f':
+		return keyWordRight, nil
+	case 'd':
+		return keyDeleteWordRight, nil
+	case backspace, ctrlH:
+		return keyDeleteWordLeft, nil
+	case '[', 'O':
+	default:
+		return keyUnknown, nil
+	}
+
+	// Control sequence: parameters and a final byte between '@' and '~'
+	var seq []rune
+	for {
+		c, _, err := e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		seq = append(seq, c)
+		if c >= '@' && c <= '~' {
+			break
+		}
+	}
+	switch string(seq) {
+	case "A":
+		return keyUp, nil
+	case "B":
+		return keyDown, nil
+	case "C":
+		return keyRight, nil
+	case "D":
+		return keyLeft, nil
+	case "H", "1~", "7~":
+		return keyHome, nil
+	case "F", "4~", "8~":
+		return keyEnd, nil
+	case "3~":
+		return keyDelete, nil
+	case "1;5C", "1;3C":
+		return keyWordRight, nil
+	case "1;5D", "1;3D":
+		return keyWordLeft, nil
+	}
+	return keyUnknown, nil
+}
+
+func (e *Editor) refresh(l *line) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", l.prompt, string(l.buf))
+	if back := len(l.buf) - l.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+// reverseSearch runs the Ctrl-R search, the match found replaces the
+// line and the key that ended the search is returned to be handled as
+// usual, 0 when the search was cancelled or only moved the cursor
+func (e *Editor) reverseSearch(l *line) (rune, error) {
+	original := string(l.buf)
+	query := ""
+	match := -1
+	for {
+		found := ""
+		if match != -1 {
+			found = e.history[match]
+		}
+		fmt.Fprintf(e.out, "\r(reverse-i-search)`%s': %s\x1b[K", query, found)
+
+		r, err := e.readKey()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case r == ctrlR:
+			if match > 0 {
+				if m := e.find(query, match-1); m != -1 {
+					match = m
+				}
+			}
+		case r == backspace || r == ctrlH:
+			if query != "" {
+				q := []rune(query)
+				query = string(q[:len(q)-1])
+				match = e.find(query, len(e.history)-1)
+			}
+		case r == ctrlG || r == ctrlC:
+			l.set(original)
+			return 0, nil
+		case r >= 32 && r <= unicode.MaxRune:
+			query += string(r)
+			from := len(e.history) - 1
+			if match != -1 {
+				from = match
+			}
+			match = e.find(query, from)
+		default:
+			if match != -1 {
+				l.set(e.history[match])
+			}
+			if r == esc || r == keyUnknown {
+				return 0, nil
+			}
+			return r, nil
+		}
+	}
+}
+
+// find returns the most recent entry at or before from containing query
+func (e *Editor) find(query string, from int) int {
+	if query == "" {
+		return -1
+	}
+	for n := from; n >= 0; n-- {
+		if strings.Contains(e.history[n], query) {
+			return n
+		}
+	}
+	return -1
+}
+
+func (e *Editor) complete(l *line) {
+	if e.Complete == nil {
+		return
+	}
+	start, candidates := e.Complete(l.buf, l.pos)
+	if len(candidates) == 0 {
+		return
+	}
+	word := string(l.buf[start:l.pos])
+	prefix := commonPrefix(candidates)
+	if len(prefix) > len(word) {
+		l.delete(start, l.pos)
+		for _, r := range prefix {
+			l.insert(r)
+		}
+		if len(candidates) == 1 {
+			l.insert(' ')
+		}
+		return
+	}
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+func (e *Editor) addHistory(s string) {
+	if strings.TrimSpace(s) == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == s) {
+		return
+	}
+	e.history = append(e.history, s)
+	if len(e.history) > MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory:]
+	}
+	if e.HistoryFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.HistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, s)
+}
+
+func (e *Editor) loadHistory() {
+	if e.HistoryFile == "" {
+		return
+	}
+	b, err := ioutil.ReadFile(e.HistoryFile)
+	if err != nil {
+		return
+	}
+	for _, s := range strings.Split(string(b), "\n") {
+		if s != "" {
+			e.history = append(e.history, s)
+		}
+	}
+	if len(e.history) > MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory:]
+		// Rewrite the file so it does not grow forever.
+		ioutil.WriteFile(e.HistoryFile, []byte(strings.Join(e.history, "\n")+"\n"), 0600)
+	}
+}
+
+// Helper
+
+func (l *line) insert(r rune) {
+	l.buf = append(l.buf, 0)
+	copy(l.buf[l.pos+1:], l.buf[l.pos:])
+	l.buf[l.pos] = r
+	l.pos++
+}
+
+func (l *line) delete(from, to int) {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(l.buf) {
+		to = len(l.buf)
+	}
+	if from >= to {
+		return
+	}
+	l.buf = append(l.buf[:from], l.buf[to:]...)
+	if l.pos > to {
+		l.pos -= to - from
+	} else if l.pos > from {
+		l.pos = from
+	}
+}
+
+func (l *line) set(s string) {
+	l.buf = []rune(s)
+	l.pos = len(l.buf)
+}
+
+func (l *line) wordStart() int {
+	n := l.pos
+	for n > 0 && !isWord(l.buf[n-1]) {
+		n--
+	}
+	for n > 0 && isWord(l.buf[n-1]) {
+		n--
+	}
+	return n
+}
+
+func (l *line) wordEnd() int {
+	n := l.pos
+	for n < len(l.buf) && !isWord(l.buf[n]) {
+		n++
+	}
+	for n < len(l.buf) && isWord(l.buf[n]) {
+		n++
+	}
+	return n
+}
+
+func isWord(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/reloonfire/rof-language/rof"
+)
+
+// lintConfigFile is read from the current directory when -config is
+// not given
+const lintConfigFile = ".roflint.json"
+
+// exitLintWarnings is returned by 'lint' when it finds a problem
+const exitLintWarnings = 1
+
+func lintCmd(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	configPath := flags.String("config", "", "read the enabled rules from `file`, default "+lintConfigFile)
+	listRules := flags.Bool("rules", false, "list the rules and exit")
+	addDiagnosticsFlag(flags)
+	addWarningsFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if !validDiagnosticsFormat() {
+		return exitUsage
+	}
+
+	if *listRules {
+		var rules []string
+		for rule := range rof.LintRules {
+			rules = append(rules, rule)
+		}
+		sort.Strings(rules)
+		for _, rule := range rules {
+			fmt.Printf("%-20s %s\n", rule, rof.LintRules[rule])
+		}
+		return exitOK
+	}
+
+	config := rof.LintConfig{}
+	path := *configPath
+	if path == "" {
+		if _, err := os.Stat(lintConfigFile); err == nil {
+			path = lintConfigFile
+		}
+	}
+	if path != "" {
+		var err error
+		if config, err = rof.LoadLintConfig(path); err != nil {
+			fmt.Fprintln(os.Stderr, "rof:", err)
+			return exitUsage
+		}
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: rof lint [-config file] <files...>")
+		return exitUsage
+	}
+	code := exitOK
+	for _, name := range files {
+		var b []byte
+		var err error
+		if name == "-" {
+			b, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			b, err = ioutil.ReadFile(name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rof:", err)
+			return exitIOError
+		}
+		// The errors and warnings of the scanner, parser and resolver
+		// are reported as 'rof run' reports them.
+		reported := warningsReported
+		if _, c := parse(name, string(b)); c != exitOK {
+			code = maxCode(code, c)
+			continue
+		}
+		if warningsReported > reported {
+			code = maxCode(code, exitLintWarnings)
+		}
+		warnings, err := rof.Lint(string(b), config)
+		if err != nil {
+			reportErrors(name, string(b), err)
+			code = maxCode(code, syntaxErrorCode(err))
+			continue
+		}
+		for _, w := range warnings {
+			if diagnosticsFormat == "text" {
+				fmt.Printf("%s:%d: %s [%s]\n", name, w.Line, w.Message, w.Rule)
+			} else {
+				report(name, string(b), w.Diagnostic())
+			}
+		}
+		if len(warnings) > 0 {
+			code = maxCode(code, exitLintWarnings)
+		}
+	}
+	return code
+}
@@ -0,0 +1,469 @@
+package lsp
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/reloonfire/rof-language/rof"
+)
+
+// document - Open file and the result of analyzing its last version
+type document struct {
+	uri  string
+	text string
+	// offsets holds the byte offset of the start of each line
+	offsets     []int
+	tokens      []rof.Token
+	stmts       []rof.Stmt
+	diagnostics []Diagnostic
+	symbols     []*symbol
+	// uses maps the position of each identifier naming a symbol,
+	// declarations included
+	uses map[tokenPos]*symbol
+}
+
+type tokenPos struct {
+	line, column int
+}
+
+func posOf(t rof.Token) tokenPos {
+	return tokenPos{t.Line, t.Column}
+}
+
+// analyze scans, parses and checks text. Syntax errors do not stop the
+// analysis: the declarations that parse are still resolved.
+func analyze(uri, text string, config rof.LintConfig) *document {
+	d := &document{uri: uri, text: text, offsets: []int{0}, uses: map[tokenPos]*symbol{}}
+	for n, c := range text {
+		if c == '\n' {
+			d.offsets = append(d.offsets, n+1)
+		}
+	}
+
+	sc := rof.NewScanner(text)
+	sc.KeepTrivia = true
+	d.tokens = sc.Scan()
+	// The scanner diagnostics and the warnings go through a sink for the
+	// '// rof:allow' comments to apply
+	sink := rof.NewSink(rof.WarningOptions{}, d.tokens)
+	for _, diag := range sc.Diagnostics {
+		sink.Report(diag)
+	}
+
+	parser := rof.Parser{File: uri, Tokens: d.tokens, Quiet: true}
+	stmts, errs := parser.Parse()
+	d.stmts = stmts
+	for _, err := range errs {
+		pe := err.(*rof.ParseError)
+		d.addDiagnostic(d.tokenRange(pe.Token), SeverityError, pe.Code, diagnosticMessage(pe.Diagnostic()))
+	}
+	for _, diag := range parser.Warnings {
+		sink.Report(diag)
+	}
+	for _, diag := range (&rof.Resolver{}).Resolve(d.stmts) {
+		sink.Report(diag)
+	}
+	for _, diag := range sink.Diagnostics {
+		d.addDiagnostic(d.spanRange(diag.Span), severityOf(diag.Severity), diag.Code, diagnosticMessage(diag))
+	}
+	for _, err := range rof.NewChecker().Check(d.stmts) {
+		te := err.(*rof.TypeError)
+		d.addDiagnostic(d.tokenRange(te.Token), SeverityError, te.Code, diagnosticMessage(te.Diagnostic()))
+	}
+	if !d.hasErrors() {
+		warnings, _ := rof.Lint(text, config)
+		for _, w := range warnings {
+			d.addDiagnostic(d.lineRange(w.Line), SeverityWarning, w.Rule, w.Message)
+		}
+	}
+
+	newResolver(d).resolve(d.stmts)
+	return d
+}
+
+func (d *document) addDiagnostic(r Range, severity int, code, message string) {
+	d.diagnostics = append(d.diagnostics, Diagnostic{Range: r, Severity: severity, Code: code, Source: "rof", Message: message})
+}
+
+func (d *document) hasErrors() bool {
+	for _, diag := range d.diagnostics {
+		if diag.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// severityOf returns the protocol severity of a diagnostic
+func severityOf(s rof.Severity) int {
+	switch s {
+	case rof.SeverityWarning:
+		return SeverityWarning
+	case rof.SeverityInfo:
+		return SeverityInformation
+	}
+	return SeverityError
+}
+
+// diagnosticMessage returns the message of a diagnostic followed by its
+// notes and help, editors have no room for the source excerpt
+func diagnosticMessage(diag rof.Diagnostic) string {
+	message := diag.Message
+	for _, note := range diag.Notes {
+		message += "\nnote: " + note
+	}
+	for _, help := range diag.Help {
+		message += "\nhelp: " + help
+	}
+	for _, s := range diag.Suggestions {
+		message += "\nhelp: " + s.Message
+	}
+	return message
+}
+
+// Positions: tokens count lines from 1 and columns in bytes from 1, the
+// protocol counts both from 0 and columns in UTF-16 code units.
+
+func (d *document) line(n int) string {
+	if n < 0 || n >= len(d.offsets) {
+		return ""
+	}
+	end := len(d.text)
+	if n+1 < len(d.offsets) {
+		end = d.offsets[n+1] - 1
+	}
+	return d.text[d.offsets[n]:end]
+}
+
+// position converts a token line and column
+func (d *document) position(line, column int) Position {
+	text := d.line(line - 1)
+	if column-1 > len(text) {
+		column = len(text) + 1
+	}
+	return Position{line - 1, utf16Len(text[:column-1])}
+}
+
+// offsetPosition converts a byte offset in the text
+func (d *document) offsetPosition(offset int) Position {
+	line := sort.SearchInts(d.offsets, offset+1) - 1
+	return d.position(line+1, offset-d.offsets[line]+1)
+}
+
+// tokenPosition converts a protocol position to a token line and column
+func (d *document) tokenPosition(p Position) (int, int) {
+	text := d.line(p.Line)
+	units := 0
+	for n, c := range text {
+		if units >= p.Character {
+			return p.Line + 1, n + 1
+		}
+		units += utf16Len(string(c))
+	}
+	return p.Line + 1, len(text) + 1
+}
+
+func (d *document) tokenRange(t rof.Token) Range {
+	start := d.position(t.Line, t.Column)
+	if t.TokenType == rof.EOF {
+		return Range{start, start}
+	}
+	offset := d.offsets[t.Line-1] + t.Column - 1
+	return Range{start, d.offsetPosition(offset + len(t.Lexeme))}
+}
+
+// spanRange returns the range of a span, at least one character wide
+func (d *document) spanRange(s rof.Span) Range {
+	start := d.position(s.Start.Line, s.Start.Column)
+	end := d.position(s.End.Line, s.End.Column)
+	if end.Line < start.Line || end.Line == start.Line && end.Character <= start.Character {
+		end = Position{start.Line, start.Character + 1}
+	}
+	return Range{start, end}
+}
+
+func (d *document) lineRange(line int) Range {
+	return Range{Position{line - 1, 0}, Position{line - 1, utf16Len(d.line(line - 1))}}
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, c := range s {
+		if c >= 0x10000 && c != utf8.RuneError {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// tokenAt returns the token under the cursor, or ending at the cursor,
+// preferring identifiers
+func (d *document) tokenAt(p Position) (rof.Token, bool) {
+	line, column := d.tokenPosition(p)
+	var found rof.Token
+	ok := false
+	for _, t := range d.tokens {
+		if t.TokenType == rof.EOF || t.Line != line || column < t.Column || column > t.Column+len(t.Lexeme) {
+			continue
+		}
+		if !ok || t.TokenType == rof.IDENTIFIER {
+			found, ok = t, true
+		}
+	}
+	return found, ok
+}
+
+func (d *document) symbolAt(p Position) *symbol {
+	t, ok := d.tokenAt(p)
+	if !ok || t.TokenType != rof.IDENTIFIER {
+		return nil
+	}
+	return d.uses[posOf(t)]
+}
+
+func (d *document) hover(p Position) *Hover {
+	t, ok := d.tokenAt(p)
+	if !ok {
+		return nil
+	}
+	s := d.uses[posOf(t)]
+	if s == nil {
+		return nil
+	}
+	return &Hover{
+		Contents: MarkupContent{"markdown", "```rof\n" + s.detail + "\n```"},
+		Range:    d.tokenRange(t),
+	}
+}
+
+func (d *document) definition(p Position) []Location {
+	s := d.symbolAt(p)
+	if s == nil || s.kind == builtinSymbol {
+		return []Location{}
+	}
+	return []Location{{d.uri, d.tokenRange(s.name)}}
+}
+
+func (d *document) references(p Position, includeDeclaration bool) []Location {
+	locations := []Location{}
+	s := d.symbolAt(p)
+	if s == nil {
+		return locations
+	}
+	if includeDeclaration && s.kind != builtinSymbol {
+		locations = append(locations, Location{d.uri, d.tokenRange(s.name)})
+	}
+	for _, t := range s.refs {
+		locations = append(locations, Location{d.uri, d.tokenRange(t)})
+	}
+	return locations
+}
+
+func (d *document) documentSymbols() []DocumentSymbol {
+	list := []DocumentSymbol{}
+	for _, s := range d.symbols {
+		switch s.kind {
+		case variableSymbol, recordSymbol, testSymbol:
+			list = append(list, d.documentSymbol(s))
+		}
+	}
+	return list
+}
+
+func (d *document) documentSymbol(s *symbol) DocumentSymbol {
+	r := d.tokenRange(s.name)
+	ds := DocumentSymbol{Name: s.name.Lexeme, Detail: s.detail, Kind: SymbolVariable, Range: r, SelectionRange: r}
+	switch s.kind {
+	case recordSymbol:
+		ds.Kind = SymbolStruct
+		for _, f := range s.fields {
+			ds.Children = append(ds.Children, d.documentSymbol(f))
+		}
+	case fieldSymbol:
+		ds.Kind = SymbolField
+	case testSymbol:
+		ds.Kind = SymbolMethod
+	}
+	return ds
+}
+
+// completion proposes the keywords and the names visible at the cursor.
+// Scopes are tracked on the tokens rather than on the syntax tree, so the
+// statement being typed does not need to parse.
+func (d *document) completion(p Position) []CompletionItem {
+	line, column := d.tokenPosition(p)
+	var before []rof.Token
+	for _, t := range d.tokens {
+		if t.TokenType == rof.EOF || t.Line > line || t.Line == line && t.Column >= column {
+			break
+		}
+		before = append(before, t)
+	}
+	// Drop the word being typed.
+	if n := len(before); n > 0 && before[n-1].Line == line && before[n-1].Column+len(before[n-1].Lexeme) == column {
+		if c := before[n-1].Lexeme[0]; c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			before = before[:n-1]
+		}
+	}
+
+	items := []CompletionItem{}
+	if n := len(before); n > 0 && before[n-1].TokenType == rof.DOT {
+		seen := map[string]bool{}
+		for _, s := range d.symbols {
+			for _, f := range s.fields {
+				if !seen[f.name.Lexeme] {
+					seen[f.name.Lexeme] = true
+					items = append(items, CompletionItem{Label: f.name.Lexeme, Kind: CompletionVariable, Detail: f.detail})
+				}
+			}
+		}
+		return items
+	}
+
+	scopes := [][]rof.Token{nil}
+	for n, t := range before {
+		switch t.TokenType {
+		case rof.LEFT_BRACE:
+			scopes = append(scopes, nil)
+		case rof.RIGHT_BRACE:
+			if len(scopes) > 1 {
+				scopes = scopes[:len(scopes)-1]
+			}
+		case rof.IDENTIFIER:
+			if n > 0 && (before[n-1].TokenType == rof.VAR || before[n-1].TokenType == rof.RECORD) {
+				scopes[len(scopes)-1] = append(scopes[len(scopes)-1], t)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for n := len(scopes) - 1; n >= 0; n-- {
+		for _, t := range scopes[n] {
+			if seen[t.Lexeme] {
+				continue
+			}
+			seen[t.Lexeme] = true
+			item := CompletionItem{Label: t.Lexeme, Kind: CompletionVariable}
+			if s := d.uses[posOf(t)]; s != nil {
+				item.Detail = s.detail
+				if s.kind == recordSymbol {
+					item.Kind = CompletionStruct
+				}
+			}
+			items = append(items, item)
+		}
+	}
+	for _, s := range builtinSymbols() {
+		if !seen[s.name.Lexeme] {
+			items = append(items, CompletionItem{Label: s.name.Lexeme, Kind: CompletionFunction, Detail: s.detail})
+		}
+	}
+	for _, k := range rof.Keywords() {
+		items = append(items, CompletionItem{Label: k, Kind: CompletionKeyword})
+	}
+	return items
+}
+
+// semanticTokens classifies the tokens and comments, multi-line ones are
+// split since clients are not required to support them
+func (d *document) semanticTokens() SemanticTokens {
+	data := []int{}
+	last := Position{}
+	emit := func(offset, length, kind int) {
+		for length > 0 {
+			start := d.offsetPosition(offset)
+			text := d.line(start.Line)
+			lineEnd := d.offsets[start.Line] + len(text)
+			n := length
+			if offset+n > lineEnd {
+				n = lineEnd - offset
+			}
+			if n > 0 {
+				deltaChar := start.Character
+				if start.Line == last.Line {
+					deltaChar -= last.Character
+				}
+				data = append(data, start.Line-last.Line, deltaChar, utf16Len(d.text[offset:offset+n]), kind, 0)
+				last = start
+			}
+			// Skip the newline.
+			n++
+			offset += n
+			length -= n
+		}
+	}
+
+	for n, t := range d.tokens {
+		offset := d.offsets[t.Line-1] + t.Column - 1
+		// Trivia end where the token starts.
+		end := offset
+		var comments [][2]int
+		for k := len(t.Leading) - 1; k >= 0; k-- {
+			tr := t.Leading[k]
+			end -= len(tr.Text)
+			if tr.Kind == rof.LineComment || tr.Kind == rof.BlockComment {
+				comments = append([][2]int{{end, len(tr.Text)}}, comments...)
+			}
+		}
+		for _, c := range comments {
+			if c[0] >= 0 {
+				emit(c[0], c[1], semanticComment)
+			}
+		}
+		if t.TokenType == rof.EOF {
+			break
+		}
+		if kind := d.classify(n); kind != -1 {
+			emit(offset, len(t.Lexeme), kind)
+		}
+	}
+	return SemanticTokens{data}
+}
+
+func (d *document) classify(n int) int {
+	t := d.tokens[n]
+	switch t.TokenType {
+	case rof.IDENTIFIER:
+		if t.Lexeme == "test" && d.tokens[n+1].TokenType == rof.STRING {
+			return semanticKeyword
+		}
+		if s := d.uses[posOf(t)]; s != nil {
+			switch s.kind {
+			case recordSymbol:
+				return semanticStruct
+			case fieldSymbol:
+				return semanticProperty
+			case builtinSymbol:
+				return semanticFunction
+			}
+			return semanticVariable
+		}
+		if n > 0 {
+			switch d.tokens[n-1].TokenType {
+			case rof.DOT:
+				return semanticProperty
+			case rof.COLON, rof.IS:
+				return semanticType
+			}
+		}
+		if d.tokens[n+1].TokenType == rof.COLON {
+			// Field name in a 'with' copy.
+			return semanticProperty
+		}
+		return semanticVariable
+	case rof.STRING:
+		return semanticString
+	case rof.NUMBER:
+		return semanticNumber
+	case rof.LEFT_PAREN, rof.RIGHT_PAREN, rof.LEFT_BRACE, rof.RIGHT_BRACE, rof.COMMA, rof.SEMICOLON, rof.DOT, rof.COLON:
+		return -1
+	}
+	for _, k := range rof.Keywords() {
+		if t.Lexeme == k {
+			return semanticKeyword
+		}
+	}
+	return semanticOperator
+}
@@ -0,0 +1,284 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/reloonfire/rof-language/rof"
+)
+
+type symbolKind int
+
+const (
+	variableSymbol symbolKind = iota
+	recordSymbol
+	fieldSymbol
+	builtinSymbol
+	testSymbol
+)
+
+// symbol - Declared name, builtins have a name token without position
+type symbol struct {
+	kind   symbolKind
+	name   rof.Token
+	detail string
+	fields []*symbol
+	refs   []rof.Token
+}
+
+// builtinSymbols returns the native functions of a new interpreter,
+// including the ones defined for the script arguments
+func builtinSymbols() []*symbol {
+	i := rof.NewInterpreter()
+	i.DefineArgs(nil)
+	var list []*symbol
+	for _, name := range i.Globals.Names() {
+		detail := name
+		if c, ok := i.Globals.Values[name].(rof.Callable); ok {
+			detail = fmt.Sprintf("builtin %s, %d argument(s)", name, c.Arity())
+			if native, ok := c.(rof.NativeFunction); ok && native.Variadic {
+				detail = fmt.Sprintf("builtin %s, at least %d argument(s)", name, c.Arity())
+			}
+		}
+		list = append(list, &symbol{kind: builtinSymbol, name: rof.Token{TokenType: rof.IDENTIFIER, Lexeme: name}, detail: detail})
+	}
+	return list
+}
+
+// resolver links every identifier of a document to its declaration,
+// following the same scoping rules as the interpreter
+type resolver struct {
+	d      *document
+	scopes []map[string]*symbol
+	// fields maps a field name to the records declaring it
+	fields map[string][]*symbol
+}
+
+func newResolver(d *document) *resolver {
+	r := &resolver{d: d, fields: map[string][]*symbol{}}
+	r.beginScope()
+	for _, s := range builtinSymbols() {
+		r.scopes[0][s.name.Lexeme] = s
+	}
+	return r
+}
+
+func (r *resolver) resolve(stmts []rof.Stmt) {
+	r.beginScope()
+	for _, stmt := range stmts {
+		r.stmt(stmt)
+	}
+	r.endScope()
+}
+
+func (r *resolver) stmt(stmt rof.Stmt) {
+	stmt.Accept(r)
+}
+
+func (r *resolver) expr(expr rof.Expr) {
+	expr.Accept(r)
+}
+
+func (r *resolver) block(stmts []rof.Stmt) {
+	r.beginScope()
+	for _, s := range stmts {
+		r.stmt(s)
+	}
+	r.endScope()
+}
+
+func (r *resolver) VisitExpressionStmt(stmt rof.Expression) interface{} {
+	r.expr(stmt.Expr)
+	return nil
+}
+
+func (r *resolver) VisitPrintStmt(stmt rof.Print) interface{} {
+	r.expr(stmt.Expr)
+	return nil
+}
+
+func (r *resolver) VisitVarStmt(stmt rof.Var) interface{} {
+	if stmt.Initializer != nil {
+		r.expr(stmt.Initializer)
+	}
+	detail := "var " + stmt.Name.Lexeme
+	if stmt.Type != nil {
+		detail += ": " + stmt.Type.Lexeme
+	}
+	r.declare(variableSymbol, stmt.Name, detail)
+	return nil
+}
+
+func (r *resolver) VisitRecordStmt(stmt rof.Record) interface{} {
+	var names []string
+	for _, f := range stmt.Fields {
+		names = append(names, f.Lexeme)
+	}
+	record := r.declare(recordSymbol, stmt.Name, "record "+stmt.Name.Lexeme+"("+strings.Join(names, ", ")+")")
+	for _, f := range stmt.Fields {
+		field := &symbol{kind: fieldSymbol, name: f, detail: "field " + f.Lexeme + " of " + stmt.Name.Lexeme}
+		record.fields = append(record.fields, field)
+		r.d.uses[posOf(f)] = field
+		r.fields[f.Lexeme] = append(r.fields[f.Lexeme], field)
+	}
+	return nil
+}
+
+func (r *resolver) VisitBlockStmt(stmt rof.Block) interface{} {
+	r.block(stmt.Statements)
+	return nil
+}
+
+func (r *resolver) VisitEmptyStmt(stmt rof.Empty) interface{} {
+	return nil
+}
+
+func (r *resolver) VisitTestStmt(stmt rof.Test) interface{} {
+	// Tests have a string for name and are not in scope.
+	test := &symbol{kind: testSymbol, name: stmt.Name, detail: "test " + stmt.Name.Lexeme}
+	r.d.symbols = append(r.d.symbols, test)
+	r.d.uses[posOf(stmt.Name)] = test
+	r.block(stmt.Body)
+	return nil
+}
+
+func (r *resolver) VisitIfStmt(stmt rof.If) interface{} {
+	r.expr(stmt.Condition)
+	r.stmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		r.stmt(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (r *resolver) VisitWhileStmt(stmt rof.While) interface{} {
+	r.expr(stmt.Condition)
+	r.stmt(stmt.Body)
+	return nil
+}
+
+func (r *resolver) VisitBinaryExpr(expr rof.Binary) interface{} {
+	r.expr(expr.Left)
+	r.expr(expr.Right)
+	return nil
+}
+
+func (r *resolver) VisitGroupingExpr(expr rof.Grouping) interface{} {
+	r.expr(expr.Expr)
+	return nil
+}
+
+func (r *resolver) VisitLiteralExpr(expr rof.Literal) interface{} {
+	return nil
+}
+
+func (r *resolver) VisitUnaryExpr(expr rof.Unary) interface{} {
+	r.expr(expr.Right)
+	return nil
+}
+
+func (r *resolver) VisitVariableExpr(expr rof.Variable) interface{} {
+	r.use(expr.Name)
+	return nil
+}
+
+func (r *resolver) VisitAssignExpr(expr rof.Assign) interface{} {
+	r.expr(expr.Value)
+	r.use(expr.Name)
+	return nil
+}
+
+func (r *resolver) VisitLogicalExpr(expr rof.Logical) interface{} {
+	r.expr(expr.Left)
+	r.expr(expr.Right)
+	return nil
+}
+
+func (r *resolver) VisitCallExpr(expr rof.Call) interface{} {
+	r.expr(expr.Callee)
+	for _, a := range expr.Args {
+		r.expr(a)
+	}
+	return nil
+}
+
+func (r *resolver) VisitIsExpr(expr rof.Is) interface{} {
+	r.expr(expr.Value)
+	r.use(expr.Type)
+	return nil
+}
+
+func (r *resolver) VisitGetExpr(expr rof.Get) interface{} {
+	r.expr(expr.Object)
+	r.useField(expr.Name)
+	return nil
+}
+
+func (r *resolver) VisitWithExpr(expr rof.With) interface{} {
+	r.expr(expr.Object)
+	for n, name := range expr.Names {
+		r.useField(name)
+		r.expr(expr.Values[n])
+	}
+	return nil
+}
+
+func (r *resolver) VisitConditionalExpr(expr rof.Conditional) interface{} {
+	r.expr(expr.Condition)
+	r.expr(expr.ThenBranch)
+	r.expr(expr.ElseBranch)
+	return nil
+}
+
+func (r *resolver) VisitCompoundExpr(expr rof.Compound) interface{} {
+	r.beginScope()
+	for _, s := range expr.Statements {
+		r.stmt(s)
+	}
+	if expr.Value != nil {
+		r.expr(expr.Value)
+	}
+	r.endScope()
+	return nil
+}
+
+func (r *resolver) VisitLoopExpr(expr rof.Loop) interface{} {
+	r.expr(expr.Condition)
+	r.expr(expr.Body)
+	return nil
+}
+
+func (r *resolver) declare(kind symbolKind, name rof.Token, detail string) *symbol {
+	s := &symbol{kind: kind, name: name, detail: detail}
+	r.scopes[len(r.scopes)-1][name.Lexeme] = s
+	r.d.symbols = append(r.d.symbols, s)
+	r.d.uses[posOf(name)] = s
+	return s
+}
+
+func (r *resolver) use(name rof.Token) {
+	for n := len(r.scopes) - 1; n >= 0; n-- {
+		if s, ok := r.scopes[n][name.Lexeme]; ok {
+			s.refs = append(s.refs, name)
+			r.d.uses[posOf(name)] = s
+			return
+		}
+	}
+}
+
+// useField links a field access when a single record has that field,
+// the record of a value is only known at runtime
+func (r *resolver) useField(name rof.Token) {
+	if records := r.fields[name.Lexeme]; len(records) == 1 {
+		records[0].refs = append(records[0].refs, name)
+		r.d.uses[posOf(name)] = records[0]
+	}
+}
+
+func (r *resolver) beginScope() {
+	r.scopes = append(r.scopes, map[string]*symbol{})
+}
+
+func (r *resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
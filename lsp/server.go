@@ -0,0 +1,254 @@
+// Package lsp implements a Language Server Protocol server for Rof
+// scripts over stdin and stdout.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/reloonfire/rof-language/helpers"
+	"github.com/reloonfire/rof-language/rof"
+)
+
+// Server - Language server keeping the open documents in memory. Only
+// full document synchronization is supported.
+type Server struct {
+	Version string
+	in      *bufio.Reader
+	out     io.Writer
+	docs    map[string]*document
+	lint    rof.LintConfig
+	// state of the protocol lifecycle
+	initialized bool
+	shutdown    bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, docs: map[string]*document{}}
+}
+
+// Run - Serve requests until the client sends 'exit', the error is nil
+// only when 'shutdown' was requested first
+func (s *Server) Run() error {
+	for {
+		body, err := helpers.ReadMessage(s.in)
+		if err == io.EOF {
+			return fmt.Errorf("connection closed without exit")
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.replyError(nil, codeParseError, err.Error())
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit without shutdown")
+			}
+			return nil
+		}
+		if err := s.handle(req); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(req request) error {
+	if !s.initialized && req.Method != "initialize" {
+		if req.ID != nil {
+			return s.replyError(req.ID, codeServerNotInitialized, "server not initialized")
+		}
+		return nil
+	}
+	if s.shutdown {
+		if req.ID != nil {
+			return s.replyError(req.ID, codeInvalidRequest, "server is shutting down")
+		}
+		return nil
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params initializeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return s.replyError(req.ID, codeInvalidParams, err.Error())
+		}
+		s.initialized = true
+		s.loadLintConfig(params.RootURI)
+		return s.reply(req.ID, s.capabilities())
+	case "initialized":
+		return nil
+	case "shutdown":
+		s.shutdown = true
+		return s.reply(req.ID, nil)
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return s.logError(req.Method, err)
+		}
+		return s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return s.logError(req.Method, err)
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			return s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return s.logError(req.Method, err)
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{params.TextDocument.URI, []Diagnostic{}})
+
+	case "textDocument/hover":
+		return s.positionRequest(req, func(d *document, p Position) interface{} {
+			if h := d.hover(p); h != nil {
+				return h
+			}
+			return nil
+		})
+	case "textDocument/definition":
+		return s.positionRequest(req, func(d *document, p Position) interface{} {
+			return d.definition(p)
+		})
+	case "textDocument/completion":
+		return s.positionRequest(req, func(d *document, p Position) interface{} {
+			return d.completion(p)
+		})
+	case "textDocument/references":
+		var params referenceParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return s.replyError(req.ID, codeInvalidParams, err.Error())
+		}
+		d := s.docs[params.TextDocument.URI]
+		if d == nil {
+			return s.reply(req.ID, []Location{})
+		}
+		return s.reply(req.ID, d.references(params.Position, params.Context.IncludeDeclaration))
+	case "textDocument/documentSymbol":
+		return s.documentRequest(req, func(d *document) interface{} {
+			return d.documentSymbols()
+		})
+	case "textDocument/semanticTokens/full":
+		return s.documentRequest(req, func(d *document) interface{} {
+			return d.semanticTokens()
+		})
+	}
+
+	if req.ID != nil {
+		return s.replyError(req.ID, codeMethodNotFound, "method not found: "+req.Method)
+	}
+	return nil
+}
+
+func (s *Server) capabilities() interface{} {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			// Full document on every change
+			"textDocumentSync":       1,
+			"hoverProvider":          true,
+			"definitionProvider":     true,
+			"referencesProvider":     true,
+			"documentSymbolProvider": true,
+			"completionProvider":     map[string]interface{}{"triggerCharacters": []string{"."}},
+			"semanticTokensProvider": map[string]interface{}{
+				"legend": map[string]interface{}{"tokenTypes": semanticTokenTypes, "tokenModifiers": []string{}},
+				"full":   true,
+			},
+		},
+		"serverInfo": map[string]string{"name": "rof", "version": s.Version},
+	}
+}
+
+// loadLintConfig reads the linter configuration of the workspace, the
+// defaults are used when there is none
+func (s *Server) loadLintConfig(rootURI string) {
+	u, err := url.Parse(rootURI)
+	if err != nil || u.Scheme != "file" {
+		return
+	}
+	path := filepath.Join(filepath.FromSlash(u.Path), ".roflint.json")
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	config, err := rof.LoadLintConfig(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rof lsp:", err)
+		return
+	}
+	s.lint = config
+}
+
+// update analyzes a new version of a document and publishes its diagnostics
+func (s *Server) update(uri, text string) error {
+	d := analyze(uri, text, s.lint)
+	s.docs[uri] = d
+	diagnostics := d.diagnostics
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{uri, diagnostics})
+}
+
+func (s *Server) positionRequest(req request, answer func(*document, Position) interface{}) error {
+	var params textDocumentPositionParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return s.replyError(req.ID, codeInvalidParams, err.Error())
+	}
+	d := s.docs[params.TextDocument.URI]
+	if d == nil {
+		return s.reply(req.ID, nil)
+	}
+	return s.reply(req.ID, answer(d, params.Position))
+}
+
+func (s *Server) documentRequest(req request, answer func(*document) interface{}) error {
+	var params documentParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return s.replyError(req.ID, codeInvalidParams, err.Error())
+	}
+	d := s.docs[params.TextDocument.URI]
+	if d == nil {
+		return s.reply(req.ID, nil)
+	}
+	return s.reply(req.ID, answer(d))
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}) error {
+	return s.send(response{"2.0", id, result})
+}
+
+func (s *Server) replyError(id *json.RawMessage, code int, message string) error {
+	return s.send(errorResponse{"2.0", id, responseError{code, message}})
+}
+
+// logError reports an error that cannot be sent as a reply, such as a
+// notification with invalid params, in its log
+func (s *Server) logError(method string, err error) error {
+	return s.notify("window/logMessage", logMessageParams{messageError, method + ": " + err.Error()})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return s.send(notification{"2.0", method, params})
+}
+
+func (s *Server) send(message interface{}) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return helpers.WriteMessage(s.out, body)
+}
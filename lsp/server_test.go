@@ -0,0 +1,185 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/reloonfire/rof-language/helpers"
+)
+
+// frame encodes a message with its Content-Length header
+func frame(message string) string {
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(message), message)
+}
+
+const (
+	initialize = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`
+	shutdown   = `{"jsonrpc":"2.0","id":9,"method":"shutdown"}`
+	exit       = `{"jsonrpc":"2.0","method":"exit"}`
+)
+
+// run serves the framed input and returns one line per message written,
+// "id: result", "id: error code" or "method: params", and the error of Run
+func run(t *testing.T, input string) ([]string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := NewServer(strings.NewReader(input), &out).Run()
+
+	var got []string
+	r := bufio.NewReader(&out)
+	for {
+		body, readErr := helpers.ReadMessage(r)
+		if readErr != nil {
+			break
+		}
+		var message struct {
+			ID     *json.RawMessage `json:"id"`
+			Method string           `json:"method"`
+			Result json.RawMessage  `json:"result"`
+			Error  *responseError   `json:"error"`
+			Params json.RawMessage  `json:"params"`
+		}
+		if err := json.Unmarshal(body, &message); err != nil {
+			t.Fatal(err)
+		}
+		id := "null"
+		if message.ID != nil {
+			id = string(*message.ID)
+		}
+		switch {
+		case message.Method != "":
+			got = append(got, message.Method+": "+string(message.Params))
+		case message.Error != nil:
+			got = append(got, fmt.Sprintf("%s: error %d", id, message.Error.Code))
+		case strings.HasPrefix(string(message.Result), "{\"capabilities\""):
+			got = append(got, id+": capabilities")
+		default:
+			got = append(got, id+": "+string(message.Result))
+		}
+	}
+	return got, err
+}
+
+func TestFraming(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+		want  []string
+	}{
+		{
+			name:  "lifecycle",
+			input: frame(initialize) + frame(shutdown) + frame(exit),
+			want:  []string{"1: capabilities", "9: null"},
+		},
+		{
+			name:  "header case and other headers",
+			input: fmt.Sprintf("content-length: %d\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n%s", len(initialize), initialize) + frame(shutdown) + frame(exit),
+			want:  []string{"1: capabilities", "9: null"},
+		},
+		{
+			name:  "invalid JSON",
+			input: frame(initialize) + frame(`{"id":`) + frame(shutdown) + frame(exit),
+			want:  []string{"1: capabilities", "null: error -32700", "9: null"},
+		},
+		{
+			name:  "missing Content-Length",
+			input: "Content-Type: text\r\n\r\n{}",
+			err:   "missing Content-Length header",
+		},
+		{
+			name:  "invalid header",
+			input: "Content-Length 2\r\n\r\n{}",
+			err:   `invalid header "Content-Length 2"`,
+		},
+		{
+			name:  "closed without exit",
+			input: frame(initialize),
+			err:   "connection closed without exit",
+			want:  []string{"1: capabilities"},
+		},
+		{
+			name:  "exit without shutdown",
+			input: frame(initialize) + frame(exit),
+			err:   "exit without shutdown",
+			want:  []string{"1: capabilities"},
+		},
+	}
+	for _, tt := range tests {
+		got, err := run(t, tt.input)
+		message := ""
+		if err != nil {
+			message = err.Error()
+		}
+		if message != tt.err {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: messages\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+func TestRequests(t *testing.T) {
+	const uri = "file:///test.rof"
+	tests := []struct {
+		name     string
+		messages []string
+		want     []string
+	}{
+		{
+			name:     "before initialize",
+			messages: []string{`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{}}`, `{"jsonrpc":"2.0","method":"initialized"}`, initialize},
+			want:     []string{"2: error -32002", "1: capabilities"},
+		},
+		{
+			name:     "unknown method",
+			messages: []string{initialize, `{"jsonrpc":"2.0","id":2,"method":"workspace/symbol"}`, `{"jsonrpc":"2.0","method":"$/cancelRequest"}`},
+			want:     []string{"1: capabilities", "2: error -32601"},
+		},
+		{
+			name:     "invalid params",
+			messages: []string{initialize, `{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":[]}`},
+			want:     []string{"1: capabilities", "2: error -32602"},
+		},
+		{
+			name:     "invalid notification params",
+			messages: []string{initialize, `{"jsonrpc":"2.0","method":"textDocument/didOpen","params":[]}`},
+			want:     []string{"1: capabilities", `window/logMessage: {"type":1,"message":"textDocument/didOpen: json: cannot unmarshal array into Go value of type lsp.didOpenParams"}`},
+		},
+		{
+			name: "diagnostics",
+			messages: []string{
+				initialize,
+				`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"` + uri + `","text":"print 1;"}}}`,
+				`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":"` + uri + `"},"contentChanges":[{"text":"print 1"}]}}`,
+				`{"jsonrpc":"2.0","method":"textDocument/didClose","params":{"textDocument":{"uri":"` + uri + `"}}}`,
+			},
+			want: []string{
+				"1: capabilities",
+				`textDocument/publishDiagnostics: {"uri":"` + uri + `","diagnostics":[]}`,
+				`textDocument/publishDiagnostics: {"uri":"` + uri + `","diagnostics":[{"range":{"start":{"line":0,"character":7},"end":{"line":0,"character":7}},"severity":1,"code":"E0201","source":"rof","message":"Expect ; after value."}]}`,
+				`textDocument/publishDiagnostics: {"uri":"` + uri + `","diagnostics":[]}`,
+			},
+		},
+		{
+			name:     "after shutdown",
+			messages: []string{initialize, shutdown, `{"jsonrpc":"2.0","id":3,"method":"textDocument/hover","params":{}}`, `{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{}}`},
+			want:     []string{"1: capabilities", "9: null", "3: error -32600"},
+		},
+	}
+	for _, tt := range tests {
+		var input strings.Builder
+		for _, m := range tt.messages {
+			input.WriteString(frame(m))
+		}
+		got, _ := run(t, input.String())
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: messages\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
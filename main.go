@@ -0,0 +1,367 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/reloonfire/rof-language/dap"
+	"github.com/reloonfire/rof-language/lsp"
+	"github.com/reloonfire/rof-language/rof"
+)
+
+const version = "0.1.0"
+
+// Exit codes, one for each stage that can fail
+const (
+	exitOK           = 0
+	exitUsage        = 64
+	exitScanError    = 65
+	exitParseError   = 66
+	exitCheckError   = 67
+	exitRuntimeError = 70
+	exitIOError      = 74
+)
+
+const usage = `Usage: rof <command> [arguments]
+
+Commands:
+  run <file> [args...]   run a script, '-' reads it from stdin
+  run -e <code> [args...] run a one-liner
+  run -trace [-trace-out file] [-trace-format text|json] <file>
+                          log every statement, assignment and call
+  repl                    start the interactive shell
+  tokens <file>           print the tokens of a script
+  ast <file>              print the syntax tree of a script as S-expressions,
+                          -format=json for JSON, -from-json to read a JSON tree
+  check <file>            verify type annotations without running
+  fmt [-check|-diff|-w] [files...]
+                          format scripts, printing them by default
+  lint [-config file] <files...>
+                          report suspicious code, -rules lists the rules
+  test [-run regexp] [-format text|tap|junit] [paths...]
+                          run the test blocks of the *_test.rof files
+  explain [code]          describe an error code such as E0102, without
+                          code list them all
+  lsp                     start a language server on stdin and stdout
+  dap                     start a debug adapter on stdin and stdout
+  version                 print the version
+
+'rof <file>' is a shortcut for 'rof run <file>'. The commands reading
+scripts take -diagnostics-format json|sarif to write their errors and
+warnings as one JSON or SARIF document on stderr, and -W to disable
+warnings (-W no-unused-local) or turn them into errors (-W error).
+`
+
+func main() {
+	os.Exit(rofMain(os.Args[1:]))
+}
+
+func rofMain(args []string) int {
+	code := command(args)
+	if err := writeDiagnostics(os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "rof:", err)
+		return maxCode(code, exitIOError)
+	}
+	return code
+}
+
+func command(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "run":
+		return runCmd(args[1:])
+	case "repl":
+		return repl()
+	case "tokens":
+		return tokensCmd(args[1:])
+	case "ast":
+		return astCmd(args[1:])
+	case "check":
+		return checkCmd(args[1:])
+	case "fmt":
+		return fmtCmd(args[1:])
+	case "lint":
+		return lintCmd(args[1:])
+	case "test":
+		return testCmd(args[1:])
+	case "explain":
+		return explainCmd(args[1:])
+	case "lsp":
+		return lspCmd()
+	case "dap":
+		return dapCmd()
+	case "version":
+		fmt.Println("rof", version)
+		return exitOK
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return exitOK
+	}
+	return runCmd(args)
+}
+
+func runCmd(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	fromJSON := flags.Bool("from-json", false, "the input is a JSON syntax tree")
+	trace := flags.Bool("trace", false, "log every statement, assignment and call to stderr")
+	traceOut := flags.String("trace-out", "", "write the trace to `file`, implies -trace")
+	traceFormat := flags.String("trace-format", "text", "trace `format`, text or json")
+	name, source, rest, code := readSource(flags, args)
+	if code != exitOK {
+		return code
+	}
+	if *traceFormat != "text" && *traceFormat != "json" {
+		fmt.Fprintf(os.Stderr, "rof: unknown trace format %q\n", *traceFormat)
+		return exitUsage
+	}
+	stmts, code := parseInput(name, source, *fromJSON)
+	if code != exitOK {
+		return code
+	}
+
+	interpreter := rof.NewInterpreter()
+	interpreter.DefineArgs(rest)
+	if *trace || *traceOut != "" {
+		var out io.Writer = os.Stderr
+		if *traceOut != "" {
+			f, err := os.Create(*traceOut)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "rof:", err)
+				return exitIOError
+			}
+			defer f.Close()
+			out = f
+		}
+		interpreter.Tracer = rof.NewTraceWriter(out, name, *traceFormat == "json")
+	}
+	if err := interpreter.Interpret(stmts); err != nil {
+		if *fromJSON && diagnosticsFormat == "text" {
+			// The source is a tree, there is no line to show
+			fmt.Fprintln(os.Stderr, "Runtime Error:", err)
+		} else {
+			report(name, source, rof.DiagnosticOf(err))
+		}
+		return exitRuntimeError
+	}
+	return exitOK
+}
+
+func tokensCmd(args []string) int {
+	name, source, _, code := readSource(flag.NewFlagSet("tokens", flag.ContinueOnError), args)
+	if code != exitOK {
+		return code
+	}
+	sc := rof.NewScanner(source)
+	tokens := sc.Scan()
+	for _, d := range sc.Diagnostics {
+		report(name, source, d)
+	}
+	for _, t := range tokens {
+		if t.Literal != nil {
+			fmt.Printf("%4d:%-3d %-14v %s %v\n", t.Line, t.Column, t.TokenType, t.Lexeme, t.Literal)
+		} else {
+			fmt.Printf("%4d:%-3d %-14v %s\n", t.Line, t.Column, t.TokenType, t.Lexeme)
+		}
+	}
+	if sc.HadError {
+		return exitScanError
+	}
+	return exitOK
+}
+
+func astCmd(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	format := flags.String("format", "sexpr", "output `format`, sexpr or json")
+	fromJSON := flags.Bool("from-json", false, "the input is a JSON syntax tree")
+	name, source, _, code := readSource(flags, args)
+	if code != exitOK {
+		return code
+	}
+	stmts, code := parseInput(name, source, *fromJSON)
+	if code != exitOK {
+		return code
+	}
+
+	switch *format {
+	case "sexpr":
+		fmt.Print(new(rof.ASTPrinter).Print(stmts))
+	case "json":
+		b, err := rof.EncodeJSON(stmts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rof:", err)
+			return exitIOError
+		}
+		fmt.Println(string(b))
+	default:
+		fmt.Fprintf(os.Stderr, "rof: unknown format %q\n", *format)
+		return exitUsage
+	}
+	return exitOK
+}
+
+func checkCmd(args []string) int {
+	name, source, _, code := readSource(flag.NewFlagSet("check", flag.ContinueOnError), args)
+	if code != exitOK {
+		return code
+	}
+	stmts, code := parse(name, source)
+	if code != exitOK {
+		return code
+	}
+	errs := rof.NewChecker().Check(stmts)
+	for _, err := range errs {
+		report(name, source, rof.DiagnosticOf(err))
+	}
+	if len(errs) > 0 {
+		return exitCheckError
+	}
+	return exitOK
+}
+
+func explainCmd(args []string) int {
+	if len(args) == 0 {
+		for _, c := range rof.ErrorCodes() {
+			fmt.Printf("%s  %s\n", c[0], c[1])
+		}
+		return exitOK
+	}
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "Usage: rof explain [code]")
+		return exitUsage
+	}
+	text, ok := rof.Explain(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "rof: unknown error code %q, 'rof explain' lists them\n", args[0])
+		return exitUsage
+	}
+	fmt.Println(text)
+	return exitOK
+}
+
+func lspCmd() int {
+	server := lsp.NewServer(os.Stdin, os.Stdout)
+	server.Version = version
+	if err := server.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "rof lsp:", err)
+		return 1
+	}
+	return exitOK
+}
+
+func dapCmd() int {
+	if err := dap.NewServer(os.Stdin, os.Stdout).Run(); err != nil && err != io.EOF {
+		fmt.Fprintln(os.Stderr, "rof dap:", err)
+		return 1
+	}
+	return exitOK
+}
+
+// readSource adds the common '-e code', '-diagnostics-format' and '-W'
+// flags to flags, parses args and reads the file argument, it returns the
+// name of the source, its content and the arguments left
+func readSource(flags *flag.FlagSet, args []string) (string, string, []string, int) {
+	eval := flags.String("e", "", "evaluate `code` instead of reading a file")
+	addDiagnosticsFlag(flags)
+	addWarningsFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return "", "", nil, exitUsage
+	}
+	if !validDiagnosticsFormat() {
+		return "", "", nil, exitUsage
+	}
+	rest := flags.Args()
+
+	if *eval != "" {
+		return "-e", *eval, rest, exitOK
+	}
+	if len(rest) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: rof %s <file> | -e <code>\n", flags.Name())
+		return "", "", nil, exitUsage
+	}
+
+	name := rest[0]
+	var b []byte
+	var err error
+	if name == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rof:", err)
+		return "", "", nil, exitIOError
+	}
+	return name, string(b), rest[1:], exitOK
+}
+
+// parseInput parses source, or decodes it when it is a JSON syntax tree
+func parseInput(name, source string, fromJSON bool) ([]rof.Stmt, int) {
+	if !fromJSON {
+		return parse(name, source)
+	}
+	stmts, err := rof.DecodeJSON([]byte(source))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rof: invalid syntax tree:", err)
+		return nil, exitParseError
+	}
+	return stmts, exitOK
+}
+
+// parse reads a script, name is the file recorded in the spans. The
+// errors are reported as diagnostics.
+func parse(name, source string) ([]rof.Stmt, int) {
+	sc := rof.NewScanner(source)
+	sc.KeepTrivia = true
+	tokens := sc.Scan()
+	sink := rof.NewSink(warningOptions, tokens)
+	// flush reports the diagnostics added to the sink since the last call,
+	// it returns code when there were errors among them
+	flushed := 0
+	flush := func(code int) int {
+		errors := 0
+		for _, d := range sink.Diagnostics[flushed:] {
+			report(name, source, d)
+			if d.Severity == rof.SeverityError {
+				errors++
+			}
+		}
+		flushed = len(sink.Diagnostics)
+		if errors > 0 {
+			return code
+		}
+		return exitOK
+	}
+
+	for _, d := range sc.Diagnostics {
+		sink.Report(d)
+	}
+	if code := flush(exitScanError); code != exitOK {
+		return nil, exitScanError
+	}
+	parser := rof.Parser{File: name, Tokens: tokens, Quiet: true}
+	stmts, errs := parser.Parse()
+	for _, err := range errs {
+		sink.Report(rof.DiagnosticOf(err))
+	}
+	for _, d := range parser.Warnings {
+		sink.Report(d)
+	}
+	if code := flush(exitParseError); code != exitOK {
+		return nil, code
+	}
+	resolver := rof.Resolver{File: name}
+	for _, d := range resolver.Resolve(stmts) {
+		sink.Report(d)
+	}
+	if code := flush(exitCheckError); code != exitOK {
+		return nil, code
+	}
+	return stmts, exitOK
+}
@@ -0,0 +1,42 @@
+# Syntax tree of Rof, read by tool/generate_ast.go to write expression.go,
+# statement.go, visitor.go, position.go, ast_copy.go and ast_equal.go.
+# Run 'go generate ./rof' after editing it.
+#
+# A node is 'expr Name: Field Type, ...' or 'stmt Name: Field Type, ...',
+# a field without a name embeds its type. Fields are listed in source
+# order, the line of a node is the line of its first field having one.
+# '//' comments before a node become its doc comment. Every node also
+# gets a Span field, its source range set by the parser.
+#
+# Field types: Token, *Token, []Token, Expr, []Expr, Stmt, []Stmt and
+# interface{} for literal values.
+
+expr Assign: Name Token, Value Expr
+expr Binary: Left Expr, Operator Token, Right Expr
+expr Grouping: Expr
+expr Literal: Value interface{}
+expr Unary: Operator Token, Right Expr
+expr Variable: Name Token
+expr Logical: Left Expr, Operator Token, Right Expr
+expr Call: Callee Expr, Paren Token, Args []Expr
+expr Is: Value Expr, Keyword Token, Type Token
+expr Get: Object Expr, Name Token
+expr With: Object Expr, Keyword Token, Names []Token, Values []Expr
+expr Conditional: Keyword Token, Condition Expr, ThenBranch Expr, ElseBranch Expr
+// Compound is a block used as an expression, Value is the trailing
+// expression without ';' and may be nil
+expr Compound: Statements []Stmt, Value Expr
+expr Loop: Condition Expr, Body Expr
+
+stmt Expression: Expr
+stmt Print: Keyword Token, Expr
+stmt Var: Name Token, Type *Token, Initializer Expr
+stmt Block: Statements []Stmt
+stmt If: Keyword Token, Condition Expr, ThenBranch Stmt, ElseBranch Stmt
+stmt While: Keyword Token, Condition Expr, Body Stmt
+stmt Record: Name Token, Fields []Token
+// Test - Named block run by 'rof test' and skipped by a normal run,
+// 'test' is only a keyword before a string at the top level
+stmt Test: Keyword Token, Name Token, Body []Stmt
+// Empty - Lone ';', accepted with an empty-statement warning
+stmt Empty: Semicolon Token
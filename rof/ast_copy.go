@@ -0,0 +1,100 @@
+// Code generated by tool/generate_ast.go from ast.spec; DO NOT EDIT.
+
+package rof
+
+// CopyExpr returns a deep copy of expr, tokens are copied by value
+func CopyExpr(expr Expr) Expr {
+	switch t := expr.(type) {
+	case Assign:
+		return Assign{Name: t.Name, Value: CopyExpr(t.Value), Span: t.Span}
+	case Binary:
+		return Binary{Left: CopyExpr(t.Left), Operator: t.Operator, Right: CopyExpr(t.Right), Span: t.Span}
+	case Grouping:
+		return Grouping{Expr: CopyExpr(t.Expr), Span: t.Span}
+	case Literal:
+		return Literal{Value: t.Value, Span: t.Span}
+	case Unary:
+		return Unary{Operator: t.Operator, Right: CopyExpr(t.Right), Span: t.Span}
+	case Variable:
+		return Variable{Name: t.Name, Span: t.Span}
+	case Logical:
+		return Logical{Left: CopyExpr(t.Left), Operator: t.Operator, Right: CopyExpr(t.Right), Span: t.Span}
+	case Call:
+		return Call{Callee: CopyExpr(t.Callee), Paren: t.Paren, Args: copyExprs(t.Args), Span: t.Span}
+	case Is:
+		return Is{Value: CopyExpr(t.Value), Keyword: t.Keyword, Type: t.Type, Span: t.Span}
+	case Get:
+		return Get{Object: CopyExpr(t.Object), Name: t.Name, Span: t.Span}
+	case With:
+		return With{Object: CopyExpr(t.Object), Keyword: t.Keyword, Names: copyTokens(t.Names), Values: copyExprs(t.Values), Span: t.Span}
+	case Conditional:
+		return Conditional{Keyword: t.Keyword, Condition: CopyExpr(t.Condition), ThenBranch: CopyExpr(t.ThenBranch), ElseBranch: CopyExpr(t.ElseBranch), Span: t.Span}
+	case Compound:
+		return Compound{Statements: copyStmts(t.Statements), Value: CopyExpr(t.Value), Span: t.Span}
+	case Loop:
+		return Loop{Condition: CopyExpr(t.Condition), Body: CopyExpr(t.Body), Span: t.Span}
+	}
+	return nil
+}
+
+// CopyStmt returns a deep copy of stmt, tokens are copied by value
+func CopyStmt(stmt Stmt) Stmt {
+	switch t := stmt.(type) {
+	case Expression:
+		return Expression{Expr: CopyExpr(t.Expr), Span: t.Span}
+	case Print:
+		return Print{Keyword: t.Keyword, Expr: CopyExpr(t.Expr), Span: t.Span}
+	case Var:
+		return Var{Name: t.Name, Type: copyTokenPtr(t.Type), Initializer: CopyExpr(t.Initializer), Span: t.Span}
+	case Block:
+		return Block{Statements: copyStmts(t.Statements), Span: t.Span}
+	case If:
+		return If{Keyword: t.Keyword, Condition: CopyExpr(t.Condition), ThenBranch: CopyStmt(t.ThenBranch), ElseBranch: CopyStmt(t.ElseBranch), Span: t.Span}
+	case While:
+		return While{Keyword: t.Keyword, Condition: CopyExpr(t.Condition), Body: CopyStmt(t.Body), Span: t.Span}
+	case Record:
+		return Record{Name: t.Name, Fields: copyTokens(t.Fields), Span: t.Span}
+	case Test:
+		return Test{Keyword: t.Keyword, Name: t.Name, Body: copyStmts(t.Body), Span: t.Span}
+	case Empty:
+		return Empty{Semicolon: t.Semicolon, Span: t.Span}
+	}
+	return nil
+}
+
+func copyTokenPtr(t *Token) *Token {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func copyTokens(tokens []Token) []Token {
+	if tokens == nil {
+		return nil
+	}
+	return append([]Token{}, tokens...)
+}
+
+func copyExprs(exprs []Expr) []Expr {
+	if exprs == nil {
+		return nil
+	}
+	c := make([]Expr, len(exprs))
+	for n, e := range exprs {
+		c[n] = CopyExpr(e)
+	}
+	return c
+}
+
+func copyStmts(stmts []Stmt) []Stmt {
+	if stmts == nil {
+		return nil
+	}
+	c := make([]Stmt, len(stmts))
+	for n, s := range stmts {
+		c[n] = CopyStmt(s)
+	}
+	return c
+}
@@ -0,0 +1,135 @@
+// Code generated by tool/generate_ast.go from ast.spec; DO NOT EDIT.
+
+package rof
+
+// EqualExpr reports whether a and b have the same shape and tokens,
+// the positions and comments of the tokens are ignored
+func EqualExpr(a, b Expr) bool {
+	switch x := a.(type) {
+	case Assign:
+		y, ok := b.(Assign)
+		return ok && equalToken(x.Name, y.Name) && EqualExpr(x.Value, y.Value)
+	case Binary:
+		y, ok := b.(Binary)
+		return ok && EqualExpr(x.Left, y.Left) && equalToken(x.Operator, y.Operator) && EqualExpr(x.Right, y.Right)
+	case Grouping:
+		y, ok := b.(Grouping)
+		return ok && EqualExpr(x.Expr, y.Expr)
+	case Literal:
+		y, ok := b.(Literal)
+		return ok && x.Value == y.Value
+	case Unary:
+		y, ok := b.(Unary)
+		return ok && equalToken(x.Operator, y.Operator) && EqualExpr(x.Right, y.Right)
+	case Variable:
+		y, ok := b.(Variable)
+		return ok && equalToken(x.Name, y.Name)
+	case Logical:
+		y, ok := b.(Logical)
+		return ok && EqualExpr(x.Left, y.Left) && equalToken(x.Operator, y.Operator) && EqualExpr(x.Right, y.Right)
+	case Call:
+		y, ok := b.(Call)
+		return ok && EqualExpr(x.Callee, y.Callee) && equalToken(x.Paren, y.Paren) && equalExprs(x.Args, y.Args)
+	case Is:
+		y, ok := b.(Is)
+		return ok && EqualExpr(x.Value, y.Value) && equalToken(x.Keyword, y.Keyword) && equalToken(x.Type, y.Type)
+	case Get:
+		y, ok := b.(Get)
+		return ok && EqualExpr(x.Object, y.Object) && equalToken(x.Name, y.Name)
+	case With:
+		y, ok := b.(With)
+		return ok && EqualExpr(x.Object, y.Object) && equalToken(x.Keyword, y.Keyword) && equalTokens(x.Names, y.Names) && equalExprs(x.Values, y.Values)
+	case Conditional:
+		y, ok := b.(Conditional)
+		return ok && equalToken(x.Keyword, y.Keyword) && EqualExpr(x.Condition, y.Condition) && EqualExpr(x.ThenBranch, y.ThenBranch) && EqualExpr(x.ElseBranch, y.ElseBranch)
+	case Compound:
+		y, ok := b.(Compound)
+		return ok && equalStmts(x.Statements, y.Statements) && EqualExpr(x.Value, y.Value)
+	case Loop:
+		y, ok := b.(Loop)
+		return ok && EqualExpr(x.Condition, y.Condition) && EqualExpr(x.Body, y.Body)
+	}
+	return a == nil && b == nil
+}
+
+// EqualStmt reports whether a and b have the same shape and tokens,
+// the positions and comments of the tokens are ignored
+func EqualStmt(a, b Stmt) bool {
+	switch x := a.(type) {
+	case Expression:
+		y, ok := b.(Expression)
+		return ok && EqualExpr(x.Expr, y.Expr)
+	case Print:
+		y, ok := b.(Print)
+		return ok && equalToken(x.Keyword, y.Keyword) && EqualExpr(x.Expr, y.Expr)
+	case Var:
+		y, ok := b.(Var)
+		return ok && equalToken(x.Name, y.Name) && equalTokenPtr(x.Type, y.Type) && EqualExpr(x.Initializer, y.Initializer)
+	case Block:
+		y, ok := b.(Block)
+		return ok && equalStmts(x.Statements, y.Statements)
+	case If:
+		y, ok := b.(If)
+		return ok && equalToken(x.Keyword, y.Keyword) && EqualExpr(x.Condition, y.Condition) && EqualStmt(x.ThenBranch, y.ThenBranch) && EqualStmt(x.ElseBranch, y.ElseBranch)
+	case While:
+		y, ok := b.(While)
+		return ok && equalToken(x.Keyword, y.Keyword) && EqualExpr(x.Condition, y.Condition) && EqualStmt(x.Body, y.Body)
+	case Record:
+		y, ok := b.(Record)
+		return ok && equalToken(x.Name, y.Name) && equalTokens(x.Fields, y.Fields)
+	case Test:
+		y, ok := b.(Test)
+		return ok && equalToken(x.Keyword, y.Keyword) && equalToken(x.Name, y.Name) && equalStmts(x.Body, y.Body)
+	case Empty:
+		y, ok := b.(Empty)
+		return ok && equalToken(x.Semicolon, y.Semicolon)
+	}
+	return a == nil && b == nil
+}
+
+func equalToken(a, b Token) bool {
+	return a.TokenType == b.TokenType && a.Lexeme == b.Lexeme && a.Literal == b.Literal
+}
+
+func equalTokenPtr(a, b *Token) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return equalToken(*a, *b)
+}
+
+func equalTokens(a, b []Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if !equalToken(a[n], b[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalExprs(a, b []Expr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if !EqualExpr(a[n], b[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStmts(a, b []Stmt) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if !EqualStmt(a[n], b[n]) {
+			return false
+		}
+	}
+	return true
+}
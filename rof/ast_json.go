@@ -0,0 +1,419 @@
+package rof
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ASTVersion - Version of the JSON schema written by EncodeJSON.
+// Every node is an object with a "kind" naming its type and an optional
+// "span" with its "file" and "start" and "end" positions, tokens are
+// objects with "type", "lexeme", "line", "column", "offset" and an
+// optional "literal".
+const ASTVersion = 2
+
+type jsonObject = map[string]interface{}
+
+type jsonAST struct {
+	Version    int           `json:"version"`
+	Statements []interface{} `json:"statements"`
+}
+
+// EncodeJSON - Encode the statements with the JSON schema
+func EncodeJSON(stmts []Stmt) ([]byte, error) {
+	ast := jsonAST{Version: ASTVersion, Statements: []interface{}{}}
+	for _, stmt := range stmts {
+		ast.Statements = append(ast.Statements, encodeStmt(stmt))
+	}
+	return json.MarshalIndent(ast, "", "  ")
+}
+
+// DecodeJSON - Rebuild the statements from the output of EncodeJSON
+func DecodeJSON(data []byte) (stmts []Stmt, err error) {
+	var ast jsonAST
+	if err := json.Unmarshal(data, &ast); err != nil {
+		return nil, err
+	}
+	if ast.Version != ASTVersion {
+		return nil, fmt.Errorf("unsupported AST version %d", ast.Version)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			stmts, err = nil, r.(error)
+		}
+	}()
+
+	return decodeStmts(ast.Statements), nil
+}
+
+func encodeStmt(stmt Stmt) interface{} {
+	if stmt == nil {
+		return nil
+	}
+	node := stmtObject(stmt)
+	encodeSpan(node, StmtSpan(stmt))
+	return node
+}
+
+func encodeExpr(expr Expr) interface{} {
+	if expr == nil {
+		return nil
+	}
+	node := exprObject(expr)
+	encodeSpan(node, ExprSpan(expr))
+	return node
+}
+
+// encodeSpan adds the source range of a node, when it has one
+func encodeSpan(node jsonObject, span Span) {
+	if span.IsZero() {
+		return
+	}
+	node["span"] = spanObject(span)
+}
+
+func spanObject(span Span) jsonObject {
+	position := func(p Position) jsonObject {
+		return jsonObject{"line": p.Line, "column": p.Column, "offset": p.Offset}
+	}
+	return jsonObject{"file": span.Start.File, "start": position(span.Start), "end": position(span.End)}
+}
+
+// jsonEncoder builds the JSON object of one node, without its span. As
+// a visitor it has a method for every node kind, so a node added to
+// ast.spec does not compile until it is encoded.
+type jsonEncoder struct{}
+
+func stmtObject(stmt Stmt) jsonObject {
+	return stmt.Accept(jsonEncoder{}).(jsonObject)
+}
+
+func exprObject(expr Expr) jsonObject {
+	return expr.Accept(jsonEncoder{}).(jsonObject)
+}
+
+func (jsonEncoder) VisitExpressionStmt(stmt Expression) interface{} {
+	return jsonObject{"kind": "Expression", "expression": encodeExpr(stmt.Expr)}
+}
+
+func (jsonEncoder) VisitPrintStmt(stmt Print) interface{} {
+	return jsonObject{"kind": "Print", "keyword": encodeToken(stmt.Keyword), "expression": encodeExpr(stmt.Expr)}
+}
+
+func (jsonEncoder) VisitVarStmt(stmt Var) interface{} {
+	var typeName interface{}
+	if stmt.Type != nil {
+		typeName = encodeToken(*stmt.Type)
+	}
+	return jsonObject{"kind": "Var", "name": encodeToken(stmt.Name), "type": typeName, "initializer": encodeExpr(stmt.Initializer)}
+}
+
+func (jsonEncoder) VisitBlockStmt(stmt Block) interface{} {
+	return jsonObject{"kind": "Block", "statements": encodeStmts(stmt.Statements)}
+}
+
+func (jsonEncoder) VisitIfStmt(stmt If) interface{} {
+	return jsonObject{"kind": "If", "keyword": encodeToken(stmt.Keyword), "condition": encodeExpr(stmt.Condition), "then": encodeStmt(stmt.ThenBranch), "else": encodeStmt(stmt.ElseBranch)}
+}
+
+func (jsonEncoder) VisitWhileStmt(stmt While) interface{} {
+	return jsonObject{"kind": "While", "keyword": encodeToken(stmt.Keyword), "condition": encodeExpr(stmt.Condition), "body": encodeStmt(stmt.Body)}
+}
+
+func (jsonEncoder) VisitRecordStmt(stmt Record) interface{} {
+	return jsonObject{"kind": "Record", "name": encodeToken(stmt.Name), "fields": encodeTokens(stmt.Fields)}
+}
+
+func (jsonEncoder) VisitTestStmt(stmt Test) interface{} {
+	return jsonObject{"kind": "Test", "keyword": encodeToken(stmt.Keyword), "name": encodeToken(stmt.Name), "body": encodeStmts(stmt.Body)}
+}
+
+func (jsonEncoder) VisitEmptyStmt(stmt Empty) interface{} {
+	return jsonObject{"kind": "Empty", "semicolon": encodeToken(stmt.Semicolon)}
+}
+
+func (jsonEncoder) VisitBinaryExpr(expr Binary) interface{} {
+	return jsonObject{"kind": "Binary", "left": encodeExpr(expr.Left), "operator": encodeToken(expr.Operator), "right": encodeExpr(expr.Right)}
+}
+
+func (jsonEncoder) VisitGroupingExpr(expr Grouping) interface{} {
+	return jsonObject{"kind": "Grouping", "expression": encodeExpr(expr.Expr)}
+}
+
+func (jsonEncoder) VisitLiteralExpr(expr Literal) interface{} {
+	return jsonObject{"kind": "Literal", "value": expr.Value}
+}
+
+func (jsonEncoder) VisitUnaryExpr(expr Unary) interface{} {
+	return jsonObject{"kind": "Unary", "operator": encodeToken(expr.Operator), "right": encodeExpr(expr.Right)}
+}
+
+func (jsonEncoder) VisitVariableExpr(expr Variable) interface{} {
+	return jsonObject{"kind": "Variable", "name": encodeToken(expr.Name)}
+}
+
+func (jsonEncoder) VisitAssignExpr(expr Assign) interface{} {
+	return jsonObject{"kind": "Assign", "name": encodeToken(expr.Name), "value": encodeExpr(expr.Value)}
+}
+
+func (jsonEncoder) VisitLogicalExpr(expr Logical) interface{} {
+	return jsonObject{"kind": "Logical", "left": encodeExpr(expr.Left), "operator": encodeToken(expr.Operator), "right": encodeExpr(expr.Right)}
+}
+
+func (jsonEncoder) VisitCallExpr(expr Call) interface{} {
+	args := []interface{}{}
+	for _, a := range expr.Args {
+		args = append(args, encodeExpr(a))
+	}
+	return jsonObject{"kind": "Call", "callee": encodeExpr(expr.Callee), "paren": encodeToken(expr.Paren), "arguments": args}
+}
+
+func (jsonEncoder) VisitIsExpr(expr Is) interface{} {
+	return jsonObject{"kind": "Is", "value": encodeExpr(expr.Value), "keyword": encodeToken(expr.Keyword), "type": encodeToken(expr.Type)}
+}
+
+func (jsonEncoder) VisitGetExpr(expr Get) interface{} {
+	return jsonObject{"kind": "Get", "object": encodeExpr(expr.Object), "name": encodeToken(expr.Name)}
+}
+
+func (jsonEncoder) VisitWithExpr(expr With) interface{} {
+	fields := []interface{}{}
+	for n, name := range expr.Names {
+		fields = append(fields, jsonObject{"name": encodeToken(name), "value": encodeExpr(expr.Values[n])})
+	}
+	return jsonObject{"kind": "With", "object": encodeExpr(expr.Object), "keyword": encodeToken(expr.Keyword), "fields": fields}
+}
+
+func (jsonEncoder) VisitConditionalExpr(expr Conditional) interface{} {
+	return jsonObject{"kind": "Conditional", "keyword": encodeToken(expr.Keyword), "condition": encodeExpr(expr.Condition), "then": encodeExpr(expr.ThenBranch), "else": encodeExpr(expr.ElseBranch)}
+}
+
+func (jsonEncoder) VisitCompoundExpr(expr Compound) interface{} {
+	return jsonObject{"kind": "Compound", "statements": encodeStmts(expr.Statements), "value": encodeExpr(expr.Value)}
+}
+
+func (jsonEncoder) VisitLoopExpr(expr Loop) interface{} {
+	return jsonObject{"kind": "Loop", "condition": encodeExpr(expr.Condition), "body": encodeExpr(expr.Body)}
+}
+
+func encodeStmts(stmts []Stmt) []interface{} {
+	list := []interface{}{}
+	for _, s := range stmts {
+		list = append(list, encodeStmt(s))
+	}
+	return list
+}
+
+func encodeToken(t Token) jsonObject {
+	token := jsonObject{"type": t.TokenType.String(), "lexeme": t.Lexeme, "line": t.Line, "column": t.Column, "offset": t.Offset}
+	if t.Literal != nil {
+		token["literal"] = t.Literal
+	}
+	return token
+}
+
+func encodeTokens(tokens []Token) []interface{} {
+	list := []interface{}{}
+	for _, t := range tokens {
+		list = append(list, encodeToken(t))
+	}
+	return list
+}
+
+// Decoding panics with an error on malformed input, DecodeJSON recovers it.
+
+func decodeStmt(v interface{}) Stmt {
+	if v == nil {
+		return nil
+	}
+	node := decodeObject(v)
+	span := decodeSpan(node["span"])
+	switch kind := node["kind"]; kind {
+	case "Expression":
+		return Expression{decodeExprField(node, "expression"), span}
+	case "Print":
+		// The keyword was added without a version change, it is optional.
+		var keyword Token
+		if node["keyword"] != nil {
+			keyword = decodeToken(node["keyword"])
+		}
+		return Print{keyword, decodeExprField(node, "expression"), span}
+	case "Var":
+		var typeName *Token
+		if node["type"] != nil {
+			t := decodeToken(node["type"])
+			typeName = &t
+		}
+		return Var{Name: decodeToken(node["name"]), Type: typeName, Initializer: decodeExpr(node["initializer"]), Span: span}
+	case "Block":
+		return Block{decodeStmts(node["statements"]), span}
+	case "If":
+		return If{decodeToken(node["keyword"]), decodeExprField(node, "condition"), decodeStmtField(node, "then"), decodeStmt(node["else"]), span}
+	case "While":
+		return While{decodeToken(node["keyword"]), decodeExprField(node, "condition"), decodeStmtField(node, "body"), span}
+	case "Record":
+		var fields []Token
+		for _, f := range decodeList(node["fields"]) {
+			fields = append(fields, decodeToken(f))
+		}
+		return Record{decodeToken(node["name"]), fields, span}
+	case "Test":
+		return Test{decodeToken(node["keyword"]), decodeToken(node["name"]), decodeStmts(node["body"]), span}
+	case "Empty":
+		return Empty{decodeToken(node["semicolon"]), span}
+	default:
+		panic(fmt.Errorf("unknown statement kind %v", kind))
+	}
+}
+
+func decodeExpr(v interface{}) Expr {
+	if v == nil {
+		return nil
+	}
+	node := decodeObject(v)
+	span := decodeSpan(node["span"])
+	switch kind := node["kind"]; kind {
+	case "Binary":
+		return Binary{decodeExprField(node, "left"), decodeToken(node["operator"]), decodeExprField(node, "right"), span}
+	case "Grouping":
+		return Grouping{decodeExprField(node, "expression"), span}
+	case "Literal":
+		switch node["value"].(type) {
+		case nil, bool, float64, string:
+			return Literal{node["value"], span}
+		}
+		panic(fmt.Errorf("invalid literal %v", node["value"]))
+	case "Unary":
+		return Unary{decodeToken(node["operator"]), decodeExprField(node, "right"), span}
+	case "Variable":
+		return Variable{decodeToken(node["name"]), span}
+	case "Assign":
+		return Assign{decodeToken(node["name"]), decodeExprField(node, "value"), span}
+	case "Logical":
+		return Logical{decodeExprField(node, "left"), decodeToken(node["operator"]), decodeExprField(node, "right"), span}
+	case "Call":
+		var args []Expr
+		for _, a := range decodeList(node["arguments"]) {
+			arg := decodeExpr(a)
+			if arg == nil {
+				panic(fmt.Errorf("null argument in a Call"))
+			}
+			args = append(args, arg)
+		}
+		return Call{decodeExprField(node, "callee"), decodeToken(node["paren"]), args, span}
+	case "Is":
+		return Is{decodeExprField(node, "value"), decodeToken(node["keyword"]), decodeToken(node["type"]), span}
+	case "Get":
+		return Get{decodeExprField(node, "object"), decodeToken(node["name"]), span}
+	case "With":
+		var names []Token
+		var values []Expr
+		for _, f := range decodeList(node["fields"]) {
+			field := decodeObject(f)
+			names = append(names, decodeToken(field["name"]))
+			values = append(values, decodeExprField(field, "value"))
+		}
+		return With{decodeExprField(node, "object"), decodeToken(node["keyword"]), names, values, span}
+	case "Conditional":
+		return Conditional{decodeToken(node["keyword"]), decodeExprField(node, "condition"), decodeExprField(node, "then"), decodeExprField(node, "else"), span}
+	case "Compound":
+		return Compound{decodeStmts(node["statements"]), decodeExpr(node["value"]), span}
+	case "Loop":
+		return Loop{decodeExprField(node, "condition"), decodeExprField(node, "body"), span}
+	default:
+		panic(fmt.Errorf("unknown expression kind %v", kind))
+	}
+}
+
+// decodeExprField decodes a required expression
+func decodeExprField(node jsonObject, field string) Expr {
+	expr := decodeExpr(node[field])
+	if expr == nil {
+		panic(fmt.Errorf("%v is missing %q", node["kind"], field))
+	}
+	return expr
+}
+
+// decodeStmtField decodes a required statement
+func decodeStmtField(node jsonObject, field string) Stmt {
+	stmt := decodeStmt(node[field])
+	if stmt == nil {
+		panic(fmt.Errorf("%v is missing %q", node["kind"], field))
+	}
+	return stmt
+}
+
+func decodeStmts(v interface{}) []Stmt {
+	stmts := []Stmt{}
+	for _, s := range decodeList(v) {
+		stmt := decodeStmt(s)
+		if stmt == nil {
+			panic(fmt.Errorf("null statement in a list"))
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+func decodeToken(v interface{}) Token {
+	node := decodeObject(v)
+	name, _ := node["type"].(string)
+	t := tokenTypeFromString(name)
+	if t == -1 {
+		panic(fmt.Errorf("unknown token type %q", name))
+	}
+	lexeme, ok := node["lexeme"].(string)
+	if !ok {
+		panic(fmt.Errorf("token %s has no lexeme", name))
+	}
+	line, _ := node["line"].(float64)
+	column, _ := node["column"].(float64)
+	offset, _ := node["offset"].(float64)
+	return Token{TokenType: t, Lexeme: lexeme, Literal: node["literal"], Line: int(line), Column: int(column), Offset: int(offset)}
+}
+
+// decodeSpan reads the optional source range of a node
+func decodeSpan(v interface{}) Span {
+	if v == nil {
+		return Span{}
+	}
+	node := decodeObject(v)
+	file, _ := node["file"].(string)
+	position := func(v interface{}) Position {
+		p := decodeObject(v)
+		line, _ := p["line"].(float64)
+		column, _ := p["column"].(float64)
+		offset, _ := p["offset"].(float64)
+		return Position{file, int(line), int(column), int(offset)}
+	}
+	return Span{position(node["start"]), position(node["end"])}
+}
+
+func decodeObject(v interface{}) jsonObject {
+	node, ok := v.(map[string]interface{})
+	if !ok {
+		panic(fmt.Errorf("expected an object, got %v", v))
+	}
+	return node
+}
+
+func decodeList(v interface{}) []interface{} {
+	if v == nil {
+		return nil
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		panic(fmt.Errorf("expected a list, got %v", v))
+	}
+	return list
+}
+
+func tokenTypeFromString(name string) TokenType {
+	for n, s := range tokenNames {
+		if s == name {
+			return TokenType(n)
+		}
+	}
+	return -1
+}
@@ -0,0 +1,75 @@
+package rof
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []string{
+		"print 1 + 2 * -3;",
+		"var a: number = (1);",
+		"var b;",
+		"a = b or c and !d;",
+		"print f(1, \"two\", nil)(true);",
+		"record Point(x, y);",
+		"print p is Point;",
+		"print p.x;",
+		"var q = p with { x: 1, y: 2 };",
+		"if (a) print 1; else { print 2; }",
+		"while (i < 3) i = i + 1;",
+		"print if (a) 1 else 2;",
+		"print { var c = 1; c };",
+		"print while (false) 1;",
+		";",
+		"test \"adds\" { assert(1 + 1 == 2); }",
+	}
+	for _, source := range tests {
+		stmts, errs := parse(t, source)
+		if len(errs) > 0 {
+			t.Errorf("%q: %v", source, errs)
+			continue
+		}
+		data, err := EncodeJSON(stmts)
+		if err != nil {
+			t.Errorf("%q: %v", source, err)
+			continue
+		}
+		decoded, err := DecodeJSON(data)
+		if err != nil {
+			t.Errorf("%q: %v", source, err)
+			continue
+		}
+		if len(decoded) != len(stmts) {
+			t.Errorf("%q: %d statements decoded, want %d", source, len(decoded), len(stmts))
+			continue
+		}
+		for n := range stmts {
+			if !EqualStmt(stmts[n], decoded[n]) {
+				t.Errorf("%q: decoded %s", source, (&ASTPrinter{}).PrintStmt(decoded[n]))
+			}
+			if StmtSpan(decoded[n]) != StmtSpan(stmts[n]) {
+				t.Errorf("%q: span %v decoded as %v", source, StmtSpan(stmts[n]), StmtSpan(decoded[n]))
+			}
+		}
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`{"version": 1, "statements": []}`, "unsupported AST version 1"},
+		{`{"version": 2, "statements": [{"kind": "Goto"}]}`, "unknown statement kind Goto"},
+		{`{"version": 2, "statements": [{"kind": "Expression"}]}`, `Expression is missing "expression"`},
+		{`{"version": 2, "statements": [{"kind": "Expression", "expression": {"kind": "Literal", "value": [1]}}]}`, "invalid literal"},
+		{`{"version": 2`, "unexpected end of JSON input"},
+	}
+	for _, tt := range tests {
+		_, err := DecodeJSON([]byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", tt.data, err, tt.want)
+		}
+	}
+}
@@ -1,88 +1,186 @@
 package rof
 
-/*
 import (
-	"fmt"
-	"reflect"
+	"strconv"
+	"strings"
 )
 
+// ASTPrinter - Print the syntax tree as Lisp-style S-expressions
 type ASTPrinter struct {
 }
 
-func AcceptMethodCall(expr interface{}, input []reflect.Value) reflect.Value {
-	var ptr reflect.Value
-	value := reflect.ValueOf(expr)
-	if value.Type().Kind() == reflect.Ptr {
-		ptr = value
-		value = ptr.Elem() // acquire value referenced by pointer
-	} else {
-		ptr = reflect.New(reflect.TypeOf(expr)) // create new pointer
-		temp := ptr.Elem()                      // create variable to value of pointer
-		temp.Set(value)                         // set value of variable to our passed in value
+// Print returns the statements one per line
+func (a *ASTPrinter) Print(stmts []Stmt) string {
+	var sb strings.Builder
+	for _, stmt := range stmts {
+		sb.WriteString(a.PrintStmt(stmt))
+		sb.WriteString("\n")
 	}
+	return sb.String()
+}
+
+func (a *ASTPrinter) PrintStmt(stmt Stmt) string {
+	return stmt.Accept(a).(string)
+}
+
+func (a *ASTPrinter) PrintExpr(expr Expr) string {
+	return expr.Accept(a).(string)
+}
+
+func (a *ASTPrinter) VisitExpressionStmt(stmt Expression) interface{} {
+	return a.parenthesize("expr", stmt.Expr)
+}
+
+func (a *ASTPrinter) VisitPrintStmt(stmt Print) interface{} {
+	return a.parenthesize("print", stmt.Expr)
+}
 
-	var finalMethod reflect.Value
-	method := value.MethodByName("Accept")
-	if method.IsValid() {
-		finalMethod = method
+func (a *ASTPrinter) VisitVarStmt(stmt Var) interface{} {
+	name := stmt.Name.Lexeme
+	if stmt.Type != nil {
+		name += ":" + stmt.Type.Lexeme
 	}
-	// check for method on pointer
-	method = ptr.MethodByName("Accept")
-	if method.IsValid() {
-		finalMethod = method
+	if stmt.Initializer == nil {
+		return "(var " + name + ")"
+	}
+	return a.parenthesize("var "+name, stmt.Initializer)
+}
+
+func (a *ASTPrinter) VisitBlockStmt(stmt Block) interface{} {
+	return a.parenthesize("block", stmtsToParts(stmt.Statements)...)
+}
+
+func (a *ASTPrinter) VisitIfStmt(stmt If) interface{} {
+	if stmt.ElseBranch == nil {
+		return a.parenthesize("if", stmt.Condition, stmt.ThenBranch)
 	}
+	return a.parenthesize("if", stmt.Condition, stmt.ThenBranch, stmt.ElseBranch)
+}
 
-	if finalMethod.IsValid() {
-		return finalMethod.Call(input)[0]
+func (a *ASTPrinter) VisitWhileStmt(stmt While) interface{} {
+	return a.parenthesize("while", stmt.Condition, stmt.Body)
+}
+
+func (a *ASTPrinter) VisitRecordStmt(stmt Record) interface{} {
+	name := "record " + stmt.Name.Lexeme
+	for _, f := range stmt.Fields {
+		name += " " + f.Lexeme
 	}
-	return reflect.Value{}
+	return "(" + name + ")"
 }
 
-func (a *ASTPrinter) Print(expr interface{}) string {
-	return AcceptMethodCall(expr, []reflect.Value{reflect.ValueOf(a)}).Interface().(string)
+func (a *ASTPrinter) VisitTestStmt(stmt Test) interface{} {
+	return a.parenthesize("test "+stmt.Name.Lexeme, stmtsToParts(stmt.Body)...)
 }
 
-func (a *ASTPrinter) visitBinaryExpr(expr Binary) interface{} {
+func (a *ASTPrinter) VisitEmptyStmt(stmt Empty) interface{} {
+	return "(;)"
+}
 
+func (a *ASTPrinter) VisitBinaryExpr(expr Binary) interface{} {
 	return a.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
 }
 
-func (a *ASTPrinter) visitGroupingExpr(expr Grouping) interface{} {
-	return a.parenthesize("group", expr.Expression)
+func (a *ASTPrinter) VisitGroupingExpr(expr Grouping) interface{} {
+	return a.parenthesize("group", expr.Expr)
 }
 
-func (a *ASTPrinter) visitLiteralExpr(expr Literal) interface{} {
-	if expr.Value == nil {
-		return "nil"
-	}
-	return expr.Value.(interface{})
+func (a *ASTPrinter) VisitLiteralExpr(expr Literal) interface{} {
+	return printLiteral(expr.Value)
 }
 
-func (a *ASTPrinter) visitUnaryExpr(expr Unary) interface{} {
+func (a *ASTPrinter) VisitUnaryExpr(expr Unary) interface{} {
 	return a.parenthesize(expr.Operator.Lexeme, expr.Right)
 }
 
-func (a *ASTPrinter) parenthesize(name string, exprs ...interface{}) interface{} {
-	var text string
+func (a *ASTPrinter) VisitVariableExpr(expr Variable) interface{} {
+	return expr.Name.Lexeme
+}
+
+func (a *ASTPrinter) VisitAssignExpr(expr Assign) interface{} {
+	return a.parenthesize("= "+expr.Name.Lexeme, expr.Value)
+}
+
+func (a *ASTPrinter) VisitLogicalExpr(expr Logical) interface{} {
+	return a.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
+}
+
+func (a *ASTPrinter) VisitCallExpr(expr Call) interface{} {
+	return a.parenthesize("call", append([]interface{}{expr.Callee}, exprsToParts(expr.Args)...)...)
+}
+
+func (a *ASTPrinter) VisitIsExpr(expr Is) interface{} {
+	return a.parenthesize("is", expr.Value, expr.Type.Lexeme)
+}
+
+func (a *ASTPrinter) VisitGetExpr(expr Get) interface{} {
+	return a.parenthesize(".", expr.Object, expr.Name.Lexeme)
+}
+
+func (a *ASTPrinter) VisitWithExpr(expr With) interface{} {
+	parts := []interface{}{expr.Object}
+	for n, name := range expr.Names {
+		parts = append(parts, a.parenthesize(name.Lexeme, expr.Values[n]))
+	}
+	return a.parenthesize("with", parts...)
+}
+
+func (a *ASTPrinter) VisitConditionalExpr(expr Conditional) interface{} {
+	return a.parenthesize("if", expr.Condition, expr.ThenBranch, expr.ElseBranch)
+}
+
+func (a *ASTPrinter) VisitCompoundExpr(expr Compound) interface{} {
+	parts := stmtsToParts(expr.Statements)
+	if expr.Value != nil {
+		parts = append(parts, expr.Value)
+	}
+	return a.parenthesize("compound", parts...)
+}
+
+func (a *ASTPrinter) VisitLoopExpr(expr Loop) interface{} {
+	return a.parenthesize("while", expr.Condition, expr.Body)
+}
+
+// parenthesize accepts expressions, statements and already printed strings
+func (a *ASTPrinter) parenthesize(name string, parts ...interface{}) string {
+	var sb strings.Builder
 
-	text += "(" + name
-	for _, expr := range exprs {
-		value := AcceptMethodCall(expr, []reflect.Value{reflect.ValueOf(a)}).Interface()
-		text += " " + ConvertToString(value)
+	sb.WriteString("(" + name)
+	for _, part := range parts {
+		sb.WriteString(" ")
+		switch t := part.(type) {
+		case Stmt:
+			sb.WriteString(a.PrintStmt(t))
+		case Expr:
+			sb.WriteString(a.PrintExpr(t))
+		case string:
+			sb.WriteString(t)
+		}
 	}
-	text += ")"
+	sb.WriteString(")")
 
-	return text
+	return sb.String()
+}
+
+func printLiteral(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return Stringify(value)
+}
+
+func stmtsToParts(stmts []Stmt) []interface{} {
+	parts := make([]interface{}, len(stmts))
+	for n, s := range stmts {
+		parts[n] = s
+	}
+	return parts
 }
 
-func ConvertToString(value interface{}) string {
-	switch value.(type) {
-	case string:
-		return value.(string)
-	case float64:
-		return fmt.Sprintf("%5.2f", value.(float64))
-	default:
-		return ""
+func exprsToParts(exprs []Expr) []interface{} {
+	parts := make([]interface{}, len(exprs))
+	for n, e := range exprs {
+		parts[n] = e
 	}
+	return parts
 }
-*/
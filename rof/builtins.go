@@ -0,0 +1,140 @@
+package rof
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// typeNames are the names accepted on the right of the 'is' operator
+var typeNames = map[string]bool{
+	"nil":      true,
+	"bool":     true,
+	"number":   true,
+	"string":   true,
+	"function": true,
+}
+
+var builtins = []NativeFunction{
+	{FunctionName: "clock", A: 0, NativeCall: func(Interpreter, []interface{}) interface{} {
+		return float64(time.Now().Second())
+	}},
+	{FunctionName: "typeof", A: 1, NativeCall: func(i Interpreter, args []interface{}) interface{} {
+		return TypeOf(args[0])
+	}},
+	{FunctionName: "arity", A: 1, NativeCall: func(i Interpreter, args []interface{}) interface{} {
+		return float64(callableArg(args[0], "arity").Arity())
+	}},
+	{FunctionName: "name", A: 1, NativeCall: func(i Interpreter, args []interface{}) interface{} {
+		return callableArg(args[0], "name").Name()
+	}},
+	{FunctionName: "fields", A: 1, NativeCall: func(i Interpreter, args []interface{}) interface{} {
+		switch t := args[0].(type) {
+		case *RecordInstance:
+			return strings.Join(t.Type.Fields, ", ")
+		case *RecordType:
+			return strings.Join(t.Fields, ", ")
+		}
+		panic(&RuntimeError{Token: Token{TokenType: IDENTIFIER, Lexeme: "fields"}, Message: "Argument must be a record, got " + TypeOf(args[0]) + ".", Code: CodeInvalidArgument})
+	}},
+	{FunctionName: "hash", A: 1, NativeCall: func(i Interpreter, args []interface{}) interface{} {
+		// Keep the 53 bits a number can represent exactly.
+		return float64(HashValue(args[0]) & (1<<53 - 1))
+	}},
+	{FunctionName: "globals", A: 0, NativeCall: func(i Interpreter, args []interface{}) interface{} {
+		return strings.Join(i.Globals.Names(), ", ")
+	}},
+	{FunctionName: "locals", A: 0, NativeCall: func(i Interpreter, args []interface{}) interface{} {
+		return strings.Join(i.Env.Names(), ", ")
+	}},
+	{FunctionName: "assert", A: 1, NativeCall: func(i Interpreter, args []interface{}) interface{} {
+		if !i.isTruthy(args[0]) {
+			panic(&AssertionError{Token: Token{TokenType: IDENTIFIER, Lexeme: "assert"}, Message: "Assertion failed, got " + quoted(args[0]) + ".", Code: CodeAssertionFailed})
+		}
+		return nil
+	}},
+	{FunctionName: "assertEqual", A: 2, NativeCall: func(i Interpreter, args []interface{}) interface{} {
+		if !i.isEqual(args[0], args[1]) {
+			panic(&AssertionError{Token: Token{TokenType: IDENTIFIER, Lexeme: "assertEqual"}, Message: "Expected " + quoted(args[0]) + " but got " + quoted(args[1]) + ".", Code: CodeAssertionFailed})
+		}
+		return nil
+	}},
+	{FunctionName: "assertThrows", A: 1, Variadic: true, NativeCall: func(i Interpreter, args []interface{}) interface{} {
+		token := Token{TokenType: IDENTIFIER, Lexeme: "assertThrows"}
+		f := callableArg(args[0], "assertThrows")
+		if err := callCatching(i, token, f, args[1:]); err == nil {
+			panic(&AssertionError{Token: token, Message: "Expected " + f.Name() + " to fail.", Code: CodeAssertionFailed})
+		}
+		return nil
+	}},
+}
+
+// quoted stringifies a value for messages, strings are quoted so that
+// "1" and 1 can be told apart
+func quoted(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return Stringify(value)
+}
+
+// callCatching calls f with args and returns the runtime error it
+// raised, calling it with the wrong number of arguments is one
+func callCatching(i Interpreter, token Token, f Callable, args []interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	checkArity(token, f, args)
+	f.Call(i, args)
+	return nil
+}
+
+// TypeOf returns the name of the type of a runtime value, records
+// are named after their declaration
+func TypeOf(value interface{}) string {
+	switch t := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *RecordInstance:
+		return t.Type.RecordName
+	case Callable:
+		return "function"
+	}
+	return "unknown"
+}
+
+func callableArg(arg interface{}, builtin string) Callable {
+	c, ok := arg.(Callable)
+	if !ok {
+		panic(&RuntimeError{Token: Token{TokenType: IDENTIFIER, Lexeme: builtin}, Message: "Argument must be a function, got " + TypeOf(arg) + ".", Code: CodeInvalidArgument})
+	}
+	return c
+}
+
+// DefineArgs exposes the script arguments to the program through
+// argc() and argv(n)
+func (i Interpreter) DefineArgs(args []string) {
+	i.Globals.Define("argc", NativeFunction{FunctionName: "argc", A: 0, NativeCall: func(Interpreter, []interface{}) interface{} {
+		return float64(len(args))
+	}})
+	i.Globals.Define("argv", NativeFunction{FunctionName: "argv", A: 1, NativeCall: func(i Interpreter, a []interface{}) interface{} {
+		n, ok := a[0].(float64)
+		if !ok || n != float64(int(n)) || n < 0 || int(n) >= len(args) {
+			panic(&RuntimeError{Token: Token{TokenType: IDENTIFIER, Lexeme: "argv"}, Message: fmt.Sprintf("Argument index out of range, argc() is %d.", len(args)), Code: CodeIndexOutOfRange})
+		}
+		return args[int(n)]
+	}})
+}
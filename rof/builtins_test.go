@@ -0,0 +1,107 @@
+package rof
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// run interprets source and returns what it printed, one value per
+// line, and its runtime error
+func run(t *testing.T, source string) (string, error) {
+	t.Helper()
+	stmts, errs := parse(t, source)
+	if len(errs) > 0 {
+		t.Fatalf("%q: %v", source, errs)
+	}
+	var out bytes.Buffer
+	i := NewInterpreter()
+	i.Out = &out
+	err := i.Interpret(stmts)
+	return strings.TrimSuffix(out.String(), "\n"), err
+}
+
+func TestTypeOf(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"nil", "nil"},
+		{"true", "bool"},
+		{"1.5", "number"},
+		{`"a"`, "string"},
+		{"clock", "function"},
+		{"P", "function"},
+		{"P(1, 2)", "P"},
+		{"typeof(1)", "string"},
+		{"{ 1 }", "number"},
+	}
+	for _, tt := range tests {
+		got, err := run(t, "record P(x, y); print typeof("+tt.expr+");")
+		if err != nil || got != tt.want {
+			t.Errorf("typeof(%s) = %q, %v, want %q", tt.expr, got, err, tt.want)
+		}
+	}
+}
+
+func TestIs(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+		err  string
+	}{
+		{"nil is nil", "true", ""},
+		{"1 is number", "true", ""},
+		{"1 is string", "false", ""},
+		{`"a" is string`, "true", ""},
+		{"false is bool", "true", ""},
+		{"clock is function", "true", ""},
+		{"P is function", "true", ""},
+		{"P(1, 2) is P", "true", ""},
+		{"Q(1) is P", "false", ""},
+		{"1 is P", "false", ""},
+		{"P(1, 2) is function", "false", ""},
+		{"1 is integer", "", "Unknown type 'integer'."},
+		{"1 is p", "", "Unknown type 'p'."},
+	}
+	for _, tt := range tests {
+		got, err := run(t, "record P(x, y); record Q(x); var p = 1; print "+tt.expr+";")
+		message := ""
+		if err != nil {
+			message = err.(*RuntimeError).Message
+		}
+		if got != tt.want || message != tt.err {
+			t.Errorf("%s = %q, %q, want %q, %q", tt.expr, got, message, tt.want, tt.err)
+		}
+	}
+}
+
+func TestReflection(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+		err  string
+	}{
+		{"arity(P)", "2", ""},
+		{"arity(clock)", "0", ""},
+		{"arity(1)", "", "Argument must be a function, got number."},
+		{"name(P)", "P", ""},
+		{"name(typeof)", "typeof", ""},
+		{`name("P")`, "", "Argument must be a function, got string."},
+		{"fields(P)", "x, y", ""},
+		{"fields(P(1, 2))", "x, y", ""},
+		{"fields(clock)", "", "Argument must be a record, got function."},
+		{"{ var l = 1; locals() }", "l", ""},
+		{"globals() == locals()", "true", ""},
+	}
+	for _, tt := range tests {
+		got, err := run(t, "record P(x, y); print "+tt.expr+";")
+		message := ""
+		if err != nil {
+			message = err.(*RuntimeError).Message
+		}
+		if got != tt.want || message != tt.err {
+			t.Errorf("%s = %q, %q, want %q, %q", tt.expr, got, message, tt.want, tt.err)
+		}
+	}
+}
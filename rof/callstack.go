@@ -0,0 +1,67 @@
+package rof
+
+import "fmt"
+
+// Frame - Call in progress. Call is the position of the call expression
+// and Native tells the callee is a builtin rather than script code.
+type Frame struct {
+	Name   string
+	Call   Position
+	Native bool
+}
+
+func (f Frame) String() string {
+	if f.Native {
+		return fmt.Sprintf("%s (native), called at %v", f.Name, f.Call)
+	}
+	return fmt.Sprintf("%s, called at %v", f.Name, f.Call)
+}
+
+// callStack - Frames of the calls in progress, shared by the copies of
+// an interpreter made for every scope
+type callStack struct {
+	frames []Frame
+}
+
+// CallStack returns a copy of the calls in progress, the most recent last
+func (i Interpreter) CallStack() []Frame {
+	if i.stack == nil {
+		return nil
+	}
+	frames := make([]Frame, len(i.stack.frames))
+	copy(frames, i.stack.frames)
+	return frames
+}
+
+// call runs function with a frame on the stack, the runtime errors
+// raised below it get the stack of the place they were raised at
+func (i Interpreter) call(expr Call, function Callable, args []interface{}) interface{} {
+	if i.stack == nil {
+		return function.Call(i, args)
+	}
+	at := expr.Span.Start
+	if expr.Span.IsZero() {
+		at = Position{Line: expr.Paren.Line, Column: expr.Paren.Column, Offset: expr.Paren.Offset}
+	}
+	_, native := function.(NativeFunction)
+	n := len(i.stack.frames)
+	i.stack.frames = append(i.stack.frames, Frame{function.Name(), at, native})
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case *RuntimeError:
+				if t.Stack == nil {
+					t.Stack = i.CallStack()
+				}
+			case *AssertionError:
+				if t.Stack == nil {
+					t.Stack = i.CallStack()
+				}
+			}
+			i.stack.frames = i.stack.frames[:n]
+			panic(r)
+		}
+		i.stack.frames = i.stack.frames[:n]
+	}()
+	return function.Call(i, args)
+}
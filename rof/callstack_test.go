@@ -0,0 +1,110 @@
+package rof
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeErrorStack(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"print -nil;", nil},
+		{"var a = 1;\nprint typeof(arity(a));", []string{"arity (native), called at test.rof:2:14"}},
+		{"record P(x);\nprint fields(P(1)).x;", nil},
+		{"print 1;\n  assert(false);", []string{"assert (native), called at test.rof:2:3"}},
+		{"assertEqual(1, typeof(2));", []string{"assertEqual (native), called at test.rof:1:1"}},
+	}
+	for _, tt := range tests {
+		_, err := run(t, tt.source)
+		var stack []Frame
+		switch e := err.(type) {
+		case *RuntimeError:
+			stack = e.Stack
+		case *AssertionError:
+			stack = e.Stack
+		default:
+			t.Errorf("%q: error %v, want a runtime error", tt.source, err)
+			continue
+		}
+		var got []string
+		for _, f := range stack {
+			got = append(got, f.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%q: stack %q, want %q", tt.source, got, tt.want)
+		}
+		if d := DiagnosticOf(err); len(d.Stack) != len(stack) {
+			t.Errorf("%q: diagnostic has %d frames, want %d", tt.source, len(d.Stack), len(stack))
+		}
+	}
+
+	// The frames of a call are removed when it fails.
+	stmts, _ := parse(t, "print arity(1);")
+	i := NewInterpreter()
+	i.Out = &bytes.Buffer{}
+	i.Interpret(stmts)
+	if stack := i.CallStack(); len(stack) != 0 {
+		t.Errorf("stack after a failed call %v, want none", stack)
+	}
+}
+
+func TestTraceback(t *testing.T) {
+	frame := func(name string, line int) Frame {
+		return Frame{Name: name, Call: Position{Line: line, Column: 1}}
+	}
+	// traceback renders the stack of a diagnostic without a span
+	traceback := func(stack []Frame) string {
+		var out bytes.Buffer
+		NewRenderer(&out, "test.rof", "", false).Render(Diagnostic{Message: "m", Stack: stack})
+		text := strings.TrimPrefix(out.String(), "error: m\n--> test.rof\n= traceback, most recent call first:\n")
+		return strings.TrimSuffix(text, "\n\n")
+	}
+
+	got := traceback([]Frame{frame("a", 1), {Name: "clock", Call: Position{File: "lib.rof", Line: 2, Column: 5}, Native: true}})
+	want := "    clock (native), called at lib.rof:2:5\n" +
+		"    a, called at test.rof:1:1"
+	if got != want {
+		t.Errorf("traceback\n%s\nwant\n%s", got, want)
+	}
+
+	got = traceback([]Frame{frame("main", 1), frame("f", 2), frame("f", 2), frame("f", 2)})
+	want = "    f, called at test.rof:2:1\n" +
+		"    [same call repeated 2 more times]\n" +
+		"    main, called at test.rof:1:1"
+	if got != want {
+		t.Errorf("repeated calls\n%s\nwant\n%s", got, want)
+	}
+
+	// 30 distinct calls keep the 10 most recent and the 10 oldest.
+	var stack []Frame
+	for n := 1; n <= 30; n++ {
+		stack = append(stack, frame(fmt.Sprintf("f%d", n), n))
+	}
+	lines := strings.Split(traceback(stack), "\n")
+	if len(lines) != maxFrames+1 {
+		t.Fatalf("deep stack has %d lines, want %d:\n%s", len(lines), maxFrames+1, strings.Join(lines, "\n"))
+	}
+	if lines[0] != "    f30, called at test.rof:30:1" || lines[9] != "    f21, called at test.rof:21:1" ||
+		lines[10] != "    [10 frames left out]" ||
+		lines[11] != "    f10, called at test.rof:10:1" || lines[20] != "    f1, called at test.rof:1:1" {
+		t.Errorf("deep stack\n%s", strings.Join(lines, "\n"))
+	}
+
+	// A recursion counts every frame it repeats as left out.
+	stack = []Frame{frame("main", 1)}
+	for n := 0; n < 100; n++ {
+		stack = append(stack, frame("a", 2), frame("b", 3))
+	}
+	lines = strings.Split(traceback(stack), "\n")
+	if len(lines) != maxFrames+1 || lines[10] != "    [181 frames left out]" || lines[20] != "    main, called at test.rof:1:1" {
+		t.Errorf("recursion\n%s", strings.Join(lines, "\n"))
+	}
+
+	if got := traceback(nil); strings.Contains(got, "traceback") {
+		t.Errorf("empty stack gives %q", got)
+	}
+}
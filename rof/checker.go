@@ -0,0 +1,363 @@
+package rof
+
+import "fmt"
+
+// Type - Static type of an expression as seen by the Checker
+type Type string
+
+const (
+	// TypeDynamic is the type of everything the checker cannot prove,
+	// it is compatible with every other type.
+	TypeDynamic Type = "any"
+	TypeNumber  Type = "number"
+	TypeString  Type = "string"
+	TypeBool    Type = "bool"
+	TypeNil     Type = "nil"
+)
+
+var annotationTypes = map[string]Type{
+	"any":    TypeDynamic,
+	"number": TypeNumber,
+	"string": TypeString,
+	"bool":   TypeBool,
+}
+
+type TypeError RuntimeError
+
+func (te *TypeError) Error() string {
+	return fmt.Sprintf("line #%d at '%v': %s", te.Token.Line, te.Token.Lexeme, te.Message)
+}
+
+func (te *TypeError) Diagnostic() Diagnostic {
+	return (*RuntimeError)(te).Diagnostic()
+}
+
+type typeScope struct {
+	enclosing *typeScope
+	types     map[string]Type
+	// inferred holds the variables declared without an annotation
+	inferred map[string]bool
+}
+
+// Checker - Static pass over the AST verifying type annotations.
+// Unannotated variables get the type of their initializer, and become
+// dynamic when a value of another type is assigned to them, so code
+// without annotations is only checked where the types are obvious.
+type Checker struct {
+	Errors []error
+	scope  *typeScope
+}
+
+func NewChecker() *Checker {
+	return &Checker{scope: newTypeScope(nil)}
+}
+
+// Check - Verify the statements and return every type error found
+func (c *Checker) Check(stmts []Stmt) []error {
+	for _, stmt := range stmts {
+		c.checkStmt(stmt)
+	}
+	return c.Errors
+}
+
+func (c *Checker) checkStmt(stmt Stmt) {
+	stmt.Accept(c)
+}
+
+func (c *Checker) checkExpr(expr Expr) Type {
+	return expr.Accept(c).(Type)
+}
+
+func (c *Checker) VisitExpressionStmt(stmt Expression) interface{} {
+	c.checkExpr(stmt.Expr)
+	return nil
+}
+
+func (c *Checker) VisitPrintStmt(stmt Print) interface{} {
+	c.checkExpr(stmt.Expr)
+	return nil
+}
+
+func (c *Checker) VisitVarStmt(stmt Var) interface{} {
+	declared := TypeDynamic
+	if stmt.Type != nil {
+		declared = c.annotation(*stmt.Type)
+	}
+	if stmt.Initializer != nil {
+		value := c.checkExpr(stmt.Initializer)
+		if stmt.Type == nil {
+			// A nil initializer says nothing about the later values.
+			if value != TypeNil {
+				declared = value
+			}
+		} else if !compatible(declared, value) {
+			err := c.error(CodeMismatchedTypes, stmt.Name, fmt.Sprintf("Cannot initialize '%s' of type %s with %s.", stmt.Name.Lexeme, declared, value))
+			err.Label = "initialized with " + string(value)
+			err.Secondary = []Label{{TokenSpan("", *stmt.Type), "declared as " + string(declared) + " here"}}
+		}
+	}
+	c.scope.types[stmt.Name.Lexeme] = declared
+	if stmt.Type == nil {
+		c.scope.inferred[stmt.Name.Lexeme] = true
+	}
+	return nil
+}
+
+func (c *Checker) VisitBlockStmt(stmt Block) interface{} {
+	c.block(stmt.Statements)
+	return nil
+}
+
+func (c *Checker) VisitIfStmt(stmt If) interface{} {
+	c.checkExpr(stmt.Condition)
+	c.checkStmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		c.checkStmt(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (c *Checker) VisitWhileStmt(stmt While) interface{} {
+	c.loop(func() {
+		c.checkExpr(stmt.Condition)
+		c.checkStmt(stmt.Body)
+	})
+	return nil
+}
+
+func (c *Checker) VisitRecordStmt(stmt Record) interface{} {
+	c.scope.types[stmt.Name.Lexeme] = TypeDynamic
+	return nil
+}
+
+func (c *Checker) VisitTestStmt(stmt Test) interface{} {
+	c.block(stmt.Body)
+	return nil
+}
+
+func (c *Checker) VisitEmptyStmt(stmt Empty) interface{} {
+	return nil
+}
+
+func (c *Checker) VisitBinaryExpr(expr Binary) interface{} {
+	left := c.checkExpr(expr.Left)
+	right := c.checkExpr(expr.Right)
+
+	switch expr.Operator.TokenType {
+	case GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+		c.numberOperands(expr.Operator, left, right)
+		return TypeBool
+	case MINUS, SLASH, STAR:
+		c.numberOperands(expr.Operator, left, right)
+		return TypeNumber
+	case PLUS:
+		switch left {
+		case TypeString:
+			return TypeString
+		case TypeNumber:
+			if !compatible(TypeNumber, right) {
+				c.error(CodeNotANumber, expr.Operator, fmt.Sprintf("Cannot add %s to number.", right))
+			}
+			return TypeNumber
+		case TypeDynamic:
+			return TypeDynamic
+		}
+		err := c.error(CodeNotANumber, expr.Operator, "Operands must be numbers or start with a string.")
+		err.Label = "applied to a " + string(left)
+		return TypeDynamic
+	case EQUAL_EQUAL, BANG_EQUAL:
+		return TypeBool
+	}
+	return TypeDynamic
+}
+
+func (c *Checker) VisitGroupingExpr(expr Grouping) interface{} {
+	return c.checkExpr(expr.Expr)
+}
+
+func (c *Checker) VisitLiteralExpr(expr Literal) interface{} {
+	return literalType(expr.Value)
+}
+
+func (c *Checker) VisitUnaryExpr(expr Unary) interface{} {
+	right := c.checkExpr(expr.Right)
+	if expr.Operator.TokenType == MINUS {
+		if !compatible(TypeNumber, right) {
+			c.error(CodeNotANumber, expr.Operator, "Operand must be number").Label = "applied to a " + string(right)
+		}
+		return TypeNumber
+	}
+	return TypeBool
+}
+
+func (c *Checker) VisitVariableExpr(expr Variable) interface{} {
+	return c.lookup(expr.Name.Lexeme)
+}
+
+func (c *Checker) VisitAssignExpr(expr Assign) interface{} {
+	value := c.checkExpr(expr.Value)
+	declared := c.lookup(expr.Name.Lexeme)
+	if s := c.declaringScope(expr.Name.Lexeme); s != nil && s.inferred[expr.Name.Lexeme] {
+		if declared != value {
+			s.types[expr.Name.Lexeme] = TypeDynamic
+		}
+		return value
+	}
+	if !compatible(declared, value) {
+		c.error(CodeMismatchedTypes, expr.Name, fmt.Sprintf("Cannot assign %s to '%s' of type %s.", value, expr.Name.Lexeme, declared))
+	}
+	return value
+}
+
+func (c *Checker) VisitLogicalExpr(expr Logical) interface{} {
+	left, right := c.checkExpr(expr.Left), c.checkExpr(expr.Right)
+	if left == right {
+		return left
+	}
+	return TypeDynamic
+}
+
+func (c *Checker) VisitCallExpr(expr Call) interface{} {
+	c.checkExpr(expr.Callee)
+	for _, arg := range expr.Args {
+		c.checkExpr(arg)
+	}
+	return TypeDynamic
+}
+
+func (c *Checker) VisitIsExpr(expr Is) interface{} {
+	c.checkExpr(expr.Value)
+	return TypeBool
+}
+
+func (c *Checker) VisitGetExpr(expr Get) interface{} {
+	c.checkExpr(expr.Object)
+	return TypeDynamic
+}
+
+func (c *Checker) VisitWithExpr(expr With) interface{} {
+	c.checkExpr(expr.Object)
+	for _, v := range expr.Values {
+		c.checkExpr(v)
+	}
+	return TypeDynamic
+}
+
+func (c *Checker) VisitConditionalExpr(expr Conditional) interface{} {
+	c.checkExpr(expr.Condition)
+	then, elseBranch := c.checkExpr(expr.ThenBranch), c.checkExpr(expr.ElseBranch)
+	if then == elseBranch {
+		return then
+	}
+	return TypeDynamic
+}
+
+func (c *Checker) VisitCompoundExpr(expr Compound) interface{} {
+	c.beginScope()
+	defer c.endScope()
+	for _, s := range expr.Statements {
+		c.checkStmt(s)
+	}
+	if expr.Value == nil {
+		return TypeNil
+	}
+	return c.checkExpr(expr.Value)
+}
+
+func (c *Checker) VisitLoopExpr(expr Loop) interface{} {
+	c.loop(func() {
+		c.checkExpr(expr.Condition)
+		c.checkExpr(expr.Body)
+	})
+	return TypeDynamic
+}
+
+// Helper
+
+func (c *Checker) numberOperands(operator Token, left, right Type) {
+	if !compatible(TypeNumber, left) || !compatible(TypeNumber, right) {
+		c.error(CodeNotANumber, operator, "Operands must be numbers").Label = "applied to a " + string(left) + " and a " + string(right)
+	}
+}
+
+func (c *Checker) annotation(name Token) Type {
+	t, ok := annotationTypes[name.Lexeme]
+	if !ok {
+		err := c.error(CodeUnknownType, name, "Unknown type '"+name.Lexeme+"'.")
+		err.Help = []string{"use number, string, bool or any"}
+		return TypeDynamic
+	}
+	return t
+}
+
+func (c *Checker) lookup(name string) Type {
+	if s := c.declaringScope(name); s != nil {
+		return s.types[name]
+	}
+	// Globals defined by the host, like the native functions.
+	return TypeDynamic
+}
+
+func (c *Checker) declaringScope(name string) *typeScope {
+	for s := c.scope; s != nil; s = s.enclosing {
+		if _, ok := s.types[name]; ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// loop checks the condition and body of a loop twice: the first pass
+// only widens the variables assigned in the body, whose new type is
+// seen by the uses before the assignment from the second iteration on
+func (c *Checker) loop(check func()) {
+	errors := len(c.Errors)
+	check()
+	c.Errors = c.Errors[:errors]
+	check()
+}
+
+func (c *Checker) block(stmts []Stmt) {
+	c.beginScope()
+	for _, s := range stmts {
+		c.checkStmt(s)
+	}
+	c.endScope()
+}
+
+func (c *Checker) beginScope() {
+	c.scope = newTypeScope(c.scope)
+}
+
+func newTypeScope(enclosing *typeScope) *typeScope {
+	return &typeScope{enclosing: enclosing, types: make(map[string]Type), inferred: make(map[string]bool)}
+}
+
+func (c *Checker) endScope() {
+	c.scope = c.scope.enclosing
+}
+
+// error records a type error, the caller may add details to it
+func (c *Checker) error(code string, token Token, message string) *TypeError {
+	err := &TypeError{Token: token, Message: message, Code: code}
+	c.Errors = append(c.Errors, err)
+	return err
+}
+
+func literalType(value interface{}) Type {
+	switch value.(type) {
+	case float64:
+		return TypeNumber
+	case string:
+		return TypeString
+	case bool:
+		return TypeBool
+	case nil:
+		return TypeNil
+	}
+	return TypeDynamic
+}
+
+func compatible(declared, actual Type) bool {
+	return declared == TypeDynamic || actual == TypeDynamic || declared == actual
+}
@@ -0,0 +1,77 @@
+package rof
+
+import (
+	"strings"
+	"testing"
+)
+
+// parse scans and parses source quietly
+func parse(t *testing.T, source string) ([]Stmt, []error) {
+	t.Helper()
+	sc := NewScanner(source)
+	tokens := sc.Scan()
+	if sc.HadError {
+		t.Fatalf("scanning %q: %v", source, sc.Errors[0])
+	}
+	parser := Parser{File: "test.rof", Tokens: tokens, Quiet: true}
+	return parser.Parse()
+}
+
+func TestChecker(t *testing.T) {
+	tests := []struct {
+		source string
+		// the errors as "code message"
+		want []string
+	}{
+		// Annotations
+		{`var n: number = 1;`, nil},
+		{`var n: number = "a";`, []string{"E0301 Cannot initialize 'n' of type number with string."}},
+		{`var b: bool = 1 < 2;`, nil},
+		{`var s: string; s = 1;`, []string{"E0301 Cannot assign number to 's' of type string."}},
+		{`var a: any = 1; a = "x";`, nil},
+		{`var n: integer = 1;`, []string{"E0303 Unknown type 'integer'."}},
+		{`var s: string = { var t = "a"; t };`, nil},
+		{`var n: number = if (true) 1 else "a";`, nil},
+		{`var n: number = if (true) "a" else "b";`, []string{"E0301 Cannot initialize 'n' of type number with string."}},
+
+		// Operators
+		{`"a" - 1;`, []string{"E0302 Operands must be numbers"}},
+		{`print -"a";`, []string{"E0302 Operand must be number"}},
+		{`print 1 < true;`, []string{"E0302 Operands must be numbers"}},
+		{`print "a" + 1;`, nil},
+		{`print 1 + "a";`, []string{"E0302 Cannot add string to number."}},
+		{`print true + 1;`, []string{"E0302 Operands must be numbers or start with a string."}},
+		{`print 1 == "a";`, nil},
+
+		// Inferred from the initializer
+		{`var s = "a"; s - 1;`, []string{"E0302 Operands must be numbers"}},
+		{`var a = 1; var b: string = a;`, []string{"E0301 Cannot initialize 'b' of type string with number."}},
+		{`var a = 1; { var b: number = a; }`, nil},
+		{`{ var s = "a"; } var s = 1; print s - 1;`, nil},
+
+		// Dynamic
+		{`var d; print d - 1;`, nil},
+		{`var n = nil; n = 1; print n - 1;`, nil},
+		{`var a = 1; a = "x"; print a - 1;`, nil},
+		{`var s = "a"; while (true) { if (s != "a") print s - 1; s = 1; }`, nil},
+		{`var i = 0; while (i < 3) i = i + 1;`, nil},
+		{`print clock() - 1;`, nil},
+		{`print undefined - 1;`, nil},
+		{`record P(x); var p = P(1); print p.x - 1;`, nil},
+	}
+	for _, tt := range tests {
+		stmts, errs := parse(t, tt.source)
+		if len(errs) > 0 {
+			t.Errorf("%q: %v", tt.source, errs)
+			continue
+		}
+		var got []string
+		for _, err := range NewChecker().Check(stmts) {
+			e := err.(*TypeError)
+			got = append(got, e.Code+" "+e.Message)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%q: errors %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package rof
+
+import (
+	"sort"
+	"strings"
+)
+
+// Error codes, stable identifiers of the kinds of errors shown in the
+// diagnostics and explained by 'rof explain'. E01 are lexical errors,
+// E02 syntax errors, E03 type errors found by the checker or at run time
+// and E04 the other run time errors. A code is never reused.
+const (
+	CodeUnexpectedCharacter = "E0101"
+	CodeUnterminatedString  = "E0102"
+	CodeInvalidNumber       = "E0103"
+	CodeUnterminatedComment = "E0104"
+
+	CodeExpectedToken      = "E0201"
+	CodeExpectedExpression = "E0202"
+	CodeInvalidAssignment  = "E0203"
+	CodeImmutableField     = "E0204"
+	CodeDuplicateField     = "E0205"
+	CodeTooManyArguments   = "E0206"
+	CodeNestedTest         = "E0207"
+
+	CodeMismatchedTypes = "E0301"
+	CodeNotANumber      = "E0302"
+	CodeUnknownType     = "E0303"
+	CodeNotCallable     = "E0304"
+	CodeNotARecord      = "E0305"
+	CodeInvalidArgument = "E0306"
+
+	CodeUndefinedVariable = "E0401"
+	CodeUndefinedField    = "E0402"
+	CodeArityMismatch     = "E0403"
+	CodeIndexOutOfRange   = "E0404"
+	CodeAssertionFailed   = "E0405"
+)
+
+// explanations - Text printed by 'rof explain', the first line is a
+// summary
+var explanations = map[string]string{
+	CodeUnexpectedCharacter: `A character that cannot start a token was found.
+
+Rof source is made of names, numbers, strings, operators and punctuation.
+Characters such as '@', '#' or '$' are not part of the language outside
+of strings and comments:
+
+    var price = $10;
+
+Remove the character or put it in a string.`,
+
+	CodeUnterminatedString: `A string literal is not closed before the end of the file.
+
+Strings start and end with '"' and may span several lines, so a missing
+closing quote swallows the rest of the file:
+
+    print "hello;
+
+Add the closing quote:
+
+    print "hello";`,
+
+	CodeInvalidNumber: `A number literal cannot be converted to a number.
+
+Number literals are digits with an optional fractional part, such as 42
+or 3.14. This error means the literal is out of range.`,
+
+	CodeUnterminatedComment: `A block comment is not closed before the end of the file.
+
+Block comments start with '/*' and end with '*/'. They nest, every '/*'
+inside a comment needs its own '*/':
+
+    /* outer /* inner */
+
+Add the missing '*/':
+
+    /* outer /* inner */ */`,
+
+	CodeExpectedToken: `The parser expected a specific token, such as ';' or ')'.
+
+The message names the token that is missing, the source line shows where
+it was expected. A missing closing ')' or '}' is reported at the end of
+the construct, a secondary label points at the opening one:
+
+    if (x > 1 {
+        print x;
+    }
+
+Add the missing token.`,
+
+	CodeExpectedExpression: `An expression was expected but something else was found.
+
+Operators need operands and statements such as 'print' need a value:
+
+    print 1 + ;
+
+Complete the expression.`,
+
+	CodeInvalidAssignment: `The left side of '=' cannot be assigned to.
+
+Only variables can be assigned:
+
+    1 = x;
+    a + b = 2;
+
+Assign to a variable instead.`,
+
+	CodeImmutableField: `A field of a record is assigned.
+
+Records are values, their fields cannot change once built:
+
+    record Point(x, y);
+    var p = Point(1, 2);
+    p.x = 3;
+
+Make a copy with the new value using 'with':
+
+    p = p with { x: 3 };`,
+
+	CodeDuplicateField: `A record declares the same field twice.
+
+    record Point(x, x);
+
+Every field of a record needs its own name.`,
+
+	CodeTooManyArguments: `A call passes more than 255 arguments.
+
+Group the values in records instead.`,
+
+	CodeNestedTest: `A test block is declared inside another statement.
+
+Test blocks are only run by 'rof test' and must be declared at the top
+level of a file:
+
+    test "addition" {
+        assertEqual(1 + 1, 2);
+    }`,
+
+	CodeMismatchedTypes: `A value does not have the type of the variable or operator.
+
+A variable declared with a type annotation only holds values of that
+type:
+
+    var n: number = "one";
+
+'+' adds two numbers or concatenates to a string, but a number cannot be
+added to something else than a number:
+
+    print 1 + true;
+
+Change the value or the annotation, use 'any' for variables holding
+values of different types.`,
+
+	CodeNotANumber: `An arithmetic or comparison operator is applied to something else than a number.
+
+'-', '*', '/', '<', '<=', '>' and '>=' only work on numbers, '+' adds
+numbers or concatenates to a string on its left:
+
+    print "10" * 2;
+    print 1 + "0";
+
+Convert the value or change the operator.`,
+
+	CodeUnknownType: `A type name is not known.
+
+'is' accepts nil, bool, number, string, function and the names of the
+declared records. Annotations accept number, string, bool and any:
+
+    var n: int = 1;
+
+Use one of the known types.`,
+
+	CodeNotCallable: `A value that is not a function or a record is called.
+
+    var x = 1;
+    x();
+
+Only functions and record constructors can be called.`,
+
+	CodeNotARecord: `Record operations are applied to a value that is not a record.
+
+Fields can only be read with '.' and copied with 'with' on records:
+
+    var n = 1;
+    print n.x;
+
+Check the value first, 'value is Point' tells whether it is a Point.`,
+
+	CodeInvalidArgument: `A builtin function is called with an argument of the wrong kind.
+
+The message says which kind of value the builtin expects, for example
+fields() takes a record and assertThrows() a function.`,
+
+	CodeUndefinedVariable: `A variable is used or assigned before it is declared.
+
+    print count;
+
+Declare it first with 'var':
+
+    var count = 0;
+    print count;
+
+Variables declared in a block are not visible after the block.`,
+
+	CodeUndefinedField: `A record has no field with the name used.
+
+    record Point(x, y);
+    print Point(1, 2).z;
+
+Use one of the fields declared by the record, fields() lists them.`,
+
+	CodeArityMismatch: `A function or record constructor gets the wrong number of arguments.
+
+    record Point(x, y);
+    var p = Point(1);
+
+Pass one argument per parameter or field.`,
+
+	CodeIndexOutOfRange: `An index is outside of the valid range.
+
+argv(n) returns the nth argument of the script, n goes from 0 to
+argc() - 1.`,
+
+	CodeAssertionFailed: `An assertion of a test failed.
+
+assert() fails when its argument is falsey, assertEqual() when its
+arguments differ and assertThrows() when its function runs without
+error with the arguments given. 'rof test' reports the test as failed.`,
+}
+
+// Explain returns the explanation of an error code, the code is not
+// case sensitive
+func Explain(code string) (string, bool) {
+	text, ok := explanations[strings.ToUpper(code)]
+	return text, ok
+}
+
+// ErrorCodes returns the sorted list of codes with their summary
+func ErrorCodes() [][2]string {
+	var codes [][2]string
+	for code, text := range explanations {
+		codes = append(codes, [2]string{code, strings.SplitN(text, "\n", 2)[0]})
+	}
+	sort.Slice(codes, func(a, b int) bool { return codes[a][0] < codes[b][0] })
+	return codes
+}
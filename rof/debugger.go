@@ -0,0 +1,13 @@
+package rof
+
+// Debugger - Hooks called by the Interpreter while it runs, a debugger
+// can block in them to pause the program
+type Debugger interface {
+	// Statement is called before each statement, blocks included so that
+	// a loop with an empty body still reaches the debugger
+	Statement(i Interpreter, stmt Stmt)
+	// Enter and Leave surround a block expression, the only construct
+	// running statements from inside an expression
+	Enter(i Interpreter, expr Compound)
+	Leave(i Interpreter, expr Compound)
+}
@@ -0,0 +1,354 @@
+package rof
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Severity - Importance of a diagnostic
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	}
+	return "error"
+}
+
+// Label - Message attached to a range of the source
+type Label struct {
+	Span    Span
+	Message string
+}
+
+// Diagnostic - Problem found in a script, with what is needed to show
+// it in context. Span is the range the problem is about, Label is
+// printed under it and Secondary points at related code. Suggestions
+// are fixes a tool can apply, they are shown as help. Stack is the call
+// stack of a runtime error, the most recent call last.
+type Diagnostic struct {
+	Severity    Severity
+	Code        string
+	Message     string
+	Span        Span
+	Label       string
+	Secondary   []Label
+	Notes       []string
+	Help        []string
+	Suggestions []Suggestion
+	Stack       []Frame
+}
+
+func (d Diagnostic) Error() string {
+	if d.Span.IsZero() {
+		return d.Message
+	}
+	return fmt.Sprintf("%v: %s", d.Span.Start, d.Message)
+}
+
+// InFile returns the diagnostic with file set on the positions without
+// one, errors raised at run time do not know their file
+func (d Diagnostic) InFile(file string) Diagnostic {
+	inFile := func(s Span) Span {
+		if !s.IsZero() && s.Start.File == "" {
+			s.Start.File, s.End.File = file, file
+		}
+		return s
+	}
+	d.Span = inFile(d.Span)
+	secondary := make([]Label, len(d.Secondary))
+	for n, l := range d.Secondary {
+		secondary[n] = Label{inFile(l.Span), l.Message}
+	}
+	d.Secondary = secondary
+	suggestions := make([]Suggestion, len(d.Suggestions))
+	for n, s := range d.Suggestions {
+		s.Span = inFile(s.Span)
+		suggestions[n] = s
+	}
+	d.Suggestions = suggestions
+	stack := make([]Frame, len(d.Stack))
+	for n, f := range d.Stack {
+		if f.Call.File == "" {
+			f.Call.File = file
+		}
+		stack[n] = f
+	}
+	d.Stack = stack
+	return d
+}
+
+// Diagnoser - Error able to describe itself as a diagnostic
+type Diagnoser interface {
+	Diagnostic() Diagnostic
+}
+
+// DiagnosticOf returns the diagnostic of an error, errors which are not
+// Diagnosers only get a message
+func DiagnosticOf(err error) Diagnostic {
+	if d, ok := err.(Diagnoser); ok {
+		return d.Diagnostic()
+	}
+	if d, ok := err.(Diagnostic); ok {
+		return d
+	}
+	return Diagnostic{Message: err.Error()}
+}
+
+// errorSpan returns the range of the token an error is reported at, the
+// end of file has no width
+func errorSpan(t Token) Span {
+	if t.Line == 0 {
+		return Span{}
+	}
+	if t.TokenType == EOF {
+		pos := Position{Line: t.Line, Column: t.Column, Offset: t.Offset}
+		return Span{pos, pos}
+	}
+	return TokenSpan("", t)
+}
+
+// ANSI escapes used by the Renderer
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[1;31m"
+	ansiYellow = "\x1b[1;33m"
+	ansiBlue   = "\x1b[1;34m"
+	ansiCyan   = "\x1b[1;36m"
+)
+
+// Renderer - Prints diagnostics with the lines of source they are about,
+// the spans underlined:
+//
+//	error[E0401]: Undefined variable 'cout'.
+//	 --> script.rof:2:7
+//	  |
+//	2 | print cout;
+//	  |       ^^^^
+//	  |
+//	  = help: ...
+type Renderer struct {
+	Out io.Writer
+	// File is the name shown in the location line
+	File   string
+	Source string
+	// Color adds ANSI escapes
+	Color bool
+	lines []string
+}
+
+func NewRenderer(out io.Writer, file, source string, color bool) *Renderer {
+	return &Renderer{Out: out, File: file, Source: source, Color: color}
+}
+
+// Render prints a diagnostic followed by an empty line
+func (r *Renderer) Render(d Diagnostic) {
+	if r.lines == nil {
+		r.lines = strings.Split(r.Source, "\n")
+	}
+	severity := d.Severity.String()
+	if d.Code != "" {
+		severity += "[" + d.Code + "]"
+	}
+	fmt.Fprintf(r.Out, "%s%s\n", r.paint(severityColor(d.Severity), severity), r.paint(ansiBold, ": "+d.Message))
+
+	labels := d.Secondary
+	if !d.Span.IsZero() {
+		labels = append([]Label{{d.Span, d.Label}}, d.Secondary...)
+	}
+	if len(labels) == 0 {
+		// Nothing to show but the file
+		if r.File != "" {
+			fmt.Fprintf(r.Out, "%s %s\n", r.paint(ansiBlue, "-->"), r.File)
+		}
+		r.footer("", d)
+		r.traceback("", d.Stack)
+		fmt.Fprintln(r.Out)
+		return
+	}
+
+	lastLine := 0
+	for _, l := range labels {
+		if l.Span.Start.Line > lastLine {
+			lastLine = l.Span.Start.Line
+		}
+	}
+	pad := strings.Repeat(" ", len(strconv.Itoa(lastLine)))
+	gutter := r.paint(ansiBlue, pad+" |")
+
+	start := labels[0].Span.Start
+	if start.File == "" {
+		start.File = r.File
+	}
+	fmt.Fprintf(r.Out, "%s%s %v\n", pad, r.paint(ansiBlue, "-->"), start)
+	fmt.Fprintln(r.Out, gutter)
+
+	// Labels are shown in source order
+	order := make([]int, len(labels))
+	for n := range order {
+		order[n] = n
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		x, y := labels[order[a]].Span.Start, labels[order[b]].Span.Start
+		return x.Line < y.Line || x.Line == y.Line && x.Column < y.Column
+	})
+	previous := 0
+	for _, n := range order {
+		l := labels[n]
+		line := l.Span.Start.Line
+		if line != previous {
+			if previous != 0 && line > previous+1 {
+				fmt.Fprintln(r.Out, r.paint(ansiBlue, "..."))
+			}
+			fmt.Fprintf(r.Out, "%s %s\n", r.paint(ansiBlue, fmt.Sprintf("%*d |", len(pad), line)), r.line(line))
+			previous = line
+		}
+		mark, color := "-", ansiBlue
+		if n == 0 && !d.Span.IsZero() {
+			mark, color = "^", severityColor(d.Severity)
+		}
+		underline := r.underline(l.Span, mark)
+		if l.Message != "" {
+			underline += " " + l.Message
+		}
+		fmt.Fprintf(r.Out, "%s %s%s\n", gutter, r.indent(l.Span.Start), r.paint(color, underline))
+	}
+	if len(d.Notes)+len(d.Help)+len(d.Suggestions)+len(d.Stack) > 0 {
+		fmt.Fprintln(r.Out, gutter)
+	}
+	r.footer(pad+" ", d)
+	r.traceback(pad+" ", d.Stack)
+	fmt.Fprintln(r.Out)
+}
+
+// maxFrames is the number of frames shown before the middle of a deep
+// stack is left out
+const maxFrames = 20
+
+// traceback prints a call stack, the most recent call first. Runs of the
+// same call are printed once and only the ends of deep stacks are kept.
+func (r *Renderer) traceback(prefix string, stack []Frame) {
+	if len(stack) == 0 {
+		return
+	}
+	// frames counts the frames of each line
+	var lines []string
+	var frames []int
+	for n := len(stack) - 1; n >= 0; {
+		frame := stack[n]
+		if frame.Call.File == "" {
+			frame.Call.File = r.File
+		}
+		lines, frames = append(lines, frame.String()), append(frames, 1)
+		same := n - 1
+		for same >= 0 && stack[same] == stack[n] {
+			same--
+		}
+		if repeated := n - same - 1; repeated > 0 {
+			lines = append(lines, fmt.Sprintf("[same call repeated %d more times]", repeated))
+			frames = append(frames, repeated)
+		}
+		n = same
+	}
+	if len(lines) > maxFrames {
+		omitted := 0
+		for _, f := range frames[maxFrames/2 : len(lines)-maxFrames/2] {
+			omitted += f
+		}
+		tail := lines[len(lines)-maxFrames/2:]
+		lines = append(append(lines[:maxFrames/2:maxFrames/2], fmt.Sprintf("[%d frames left out]", omitted)), tail...)
+	}
+	fmt.Fprintf(r.Out, "%s%s\n", prefix, r.paint(ansiBold, "= traceback, most recent call first:"))
+	for _, line := range lines {
+		fmt.Fprintf(r.Out, "%s    %s\n", prefix, line)
+	}
+}
+
+// footer prints the notes and help of a diagnostic, indented by prefix
+func (r *Renderer) footer(prefix string, d Diagnostic) {
+	for _, note := range d.Notes {
+		fmt.Fprintf(r.Out, "%s%s %s\n", prefix, r.paint(ansiBold, "= note:"), note)
+	}
+	for _, help := range d.Help {
+		fmt.Fprintf(r.Out, "%s%s %s\n", prefix, r.paint(ansiBold, "= help:"), help)
+	}
+	for _, s := range d.Suggestions {
+		fmt.Fprintf(r.Out, "%s%s %s\n", prefix, r.paint(ansiBold, "= help:"), s.Message)
+	}
+}
+
+// line returns a line of the source, counted from 1
+func (r *Renderer) line(n int) string {
+	if n < 1 || n > len(r.lines) {
+		return ""
+	}
+	return strings.TrimRight(r.lines[n-1], "\r")
+}
+
+// indent returns the blanks aligning a mark with a position, tabs are
+// kept so the mark lines up whatever their width
+func (r *Renderer) indent(pos Position) string {
+	text := r.line(pos.Line)
+	if pos.Column-1 < len(text) {
+		text = text[:pos.Column-1]
+	}
+	var sb strings.Builder
+	for _, c := range text {
+		if c == '\t' {
+			sb.WriteRune('\t')
+		} else {
+			sb.WriteRune(' ')
+		}
+	}
+	return sb.String()
+}
+
+// underline returns the marks under a span, up to the end of its first
+// line and at least one
+func (r *Renderer) underline(s Span, mark string) string {
+	text := r.line(s.Start.Line)
+	from := s.Start.Column - 1
+	if from > len(text) {
+		from = len(text)
+	}
+	to := len(text)
+	if s.End.Line == s.Start.Line && s.End.Column-1 < to {
+		to = s.End.Column - 1
+	}
+	width := 1
+	if to > from {
+		width = utf8.RuneCountInString(text[from:to])
+	}
+	return strings.Repeat(mark, width)
+}
+
+func (r *Renderer) paint(color, text string) string {
+	if !r.Color || text == "" {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+func severityColor(s Severity) string {
+	switch s {
+	case SeverityWarning:
+		return ansiYellow
+	case SeverityInfo:
+		return ansiCyan
+	}
+	return ansiRed
+}
@@ -0,0 +1,219 @@
+package rof
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// DiagnosticsVersion - Version of the JSON schema written by
+// EncodeDiagnosticsJSON. Spans have the shape of the syntax tree spans.
+const DiagnosticsVersion = 1
+
+// EncodeDiagnosticsJSON - Encode diagnostics as a JSON document:
+//
+//	{"version": 1, "diagnostics": [{"severity": "error", "code": "E0401",
+//	  "message": "...", "span": {...}, "label": "...", "secondary": [...],
+//	  "notes": [...], "help": [...], "suggestions": [...], "stack": [...]}]}
+func EncodeDiagnosticsJSON(diagnostics []Diagnostic) ([]byte, error) {
+	list := []interface{}{}
+	for _, d := range diagnostics {
+		list = append(list, diagnosticObject(d))
+	}
+	return json.MarshalIndent(jsonObject{"version": DiagnosticsVersion, "diagnostics": list}, "", "  ")
+}
+
+func diagnosticObject(d Diagnostic) jsonObject {
+	secondary := []interface{}{}
+	for _, l := range d.Secondary {
+		secondary = append(secondary, jsonObject{"span": optionalSpan(l.Span), "message": l.Message})
+	}
+	suggestions := []interface{}{}
+	for _, s := range d.Suggestions {
+		suggestions = append(suggestions, jsonObject{"message": s.Message, "span": optionalSpan(s.Span), "replacement": s.Replacement})
+	}
+	stack := []interface{}{}
+	for n := len(d.Stack) - 1; n >= 0; n-- {
+		f := d.Stack[n]
+		stack = append(stack, jsonObject{"name": f.Name, "file": f.Call.File, "line": f.Call.Line, "column": f.Call.Column, "native": f.Native})
+	}
+	return jsonObject{
+		"severity":    d.Severity.String(),
+		"code":        d.Code,
+		"message":     d.Message,
+		"span":        optionalSpan(d.Span),
+		"label":       d.Label,
+		"secondary":   secondary,
+		"notes":       stringList(d.Notes),
+		"help":        stringList(d.Help),
+		"suggestions": suggestions,
+		"stack":       stack,
+	}
+}
+
+func optionalSpan(span Span) interface{} {
+	if span.IsZero() {
+		return nil
+	}
+	return spanObject(span)
+}
+
+func stringList(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// EncodeSARIF - Encode diagnostics as a SARIF 2.1.0 log, as uploaded to
+// code scanning services. Every code is a rule of the rof tool, the
+// stack of runtime errors is a SARIF stack, most recent call first.
+// sources maps the file names to their content, SARIF columns count code
+// points where spans count bytes.
+func EncodeSARIF(diagnostics []Diagnostic, version string, sources map[string]string) ([]byte, error) {
+	s := sarifEncoder{sources: sources, lines: map[string][]string{}}
+	rules := map[string]jsonObject{}
+	results := []interface{}{}
+	for _, d := range diagnostics {
+		if d.Code != "" && rules[d.Code] == nil {
+			rules[d.Code] = sarifRule(d.Code)
+		}
+		results = append(results, s.result(d))
+	}
+	codes := make([]string, 0, len(rules))
+	for code := range rules {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	ruleList := []interface{}{}
+	for _, code := range codes {
+		ruleList = append(ruleList, rules[code])
+	}
+
+	log := jsonObject{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []interface{}{jsonObject{
+			"tool": jsonObject{"driver": jsonObject{
+				"name":    "rof",
+				"version": version,
+				"rules":   ruleList,
+			}},
+			"columnKind": "unicodeCodePoints",
+			"results":    results,
+		}},
+	}
+	return json.MarshalIndent(log, "", "  ")
+}
+
+func sarifRule(code string) jsonObject {
+	rule := jsonObject{"id": code}
+	if text, ok := Explain(code); ok {
+		rule["shortDescription"] = jsonObject{"text": strings.SplitN(text, "\n", 2)[0]}
+		rule["fullDescription"] = jsonObject{"text": text}
+	} else if description, ok := LintRules[code]; ok {
+		rule["shortDescription"] = jsonObject{"text": description}
+	} else if description, ok := Warnings[code]; ok {
+		rule["shortDescription"] = jsonObject{"text": description}
+	}
+	return rule
+}
+
+type sarifEncoder struct {
+	sources map[string]string
+	lines   map[string][]string
+}
+
+func (s sarifEncoder) result(d Diagnostic) jsonObject {
+	levels := map[Severity]string{SeverityError: "error", SeverityWarning: "warning", SeverityInfo: "note"}
+	text := d.Message
+	for _, note := range d.Notes {
+		text += "\nnote: " + note
+	}
+	for _, help := range d.Help {
+		text += "\nhelp: " + help
+	}
+	result := jsonObject{"level": levels[d.Severity], "message": jsonObject{"text": text}}
+	if d.Code != "" {
+		result["ruleId"] = d.Code
+	}
+	if !d.Span.IsZero() {
+		result["locations"] = []interface{}{jsonObject{"physicalLocation": s.location(d.Span)}}
+	}
+
+	var related []interface{}
+	for n, l := range d.Secondary {
+		related = append(related, jsonObject{"id": n, "physicalLocation": s.location(l.Span), "message": jsonObject{"text": l.Message}})
+	}
+	if related != nil {
+		result["relatedLocations"] = related
+	}
+
+	var fixes []interface{}
+	for _, sg := range d.Suggestions {
+		fixes = append(fixes, jsonObject{
+			"description": jsonObject{"text": sg.Message},
+			"artifactChanges": []interface{}{jsonObject{
+				"artifactLocation": jsonObject{"uri": sg.Span.Start.File},
+				"replacements": []interface{}{jsonObject{
+					"deletedRegion":   s.region(sg.Span),
+					"insertedContent": jsonObject{"text": sg.Replacement},
+				}},
+			}},
+		})
+	}
+	if fixes != nil {
+		result["fixes"] = fixes
+	}
+
+	if len(d.Stack) > 0 {
+		var frames []interface{}
+		for n := len(d.Stack) - 1; n >= 0; n-- {
+			f := d.Stack[n]
+			at := Span{f.Call, f.Call}
+			frames = append(frames, jsonObject{"location": jsonObject{
+				"physicalLocation": s.location(at),
+				"message":          jsonObject{"text": f.Name},
+			}})
+		}
+		result["stacks"] = []interface{}{jsonObject{"message": jsonObject{"text": "call stack"}, "frames": frames}}
+	}
+	return result
+}
+
+func (s sarifEncoder) location(span Span) jsonObject {
+	return jsonObject{"artifactLocation": jsonObject{"uri": span.Start.File}, "region": s.region(span)}
+}
+
+// region converts a span, an empty one covers a character so that
+// viewers can highlight it
+func (s sarifEncoder) region(span Span) jsonObject {
+	start, end := s.column(span.Start), s.column(span.End)
+	if span.End == span.Start {
+		end++
+	}
+	return jsonObject{"startLine": span.Start.Line, "startColumn": start, "endLine": span.End.Line, "endColumn": end}
+}
+
+// column returns the column of a position in code points, the byte
+// column when the source is not known
+func (s sarifEncoder) column(pos Position) int {
+	lines, ok := s.lines[pos.File]
+	if !ok {
+		source, known := s.sources[pos.File]
+		if !known {
+			return pos.Column
+		}
+		lines = strings.Split(source, "\n")
+		s.lines[pos.File] = lines
+	}
+	if pos.Line < 1 || pos.Line > len(lines) {
+		return pos.Column
+	}
+	text := lines[pos.Line-1]
+	if pos.Column-1 > len(text) {
+		return pos.Column
+	}
+	return utf8.RuneCountInString(text[:pos.Column-1]) + 1
+}
@@ -0,0 +1,191 @@
+package rof
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// decoded marshals a value and decodes it back, to compare it with
+// decoded JSON
+func decoded(t *testing.T, value interface{}) interface{} {
+	t.Helper()
+	b, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	json.Unmarshal(b, &v)
+	return v
+}
+
+func sameJSON(t *testing.T, got, want interface{}) bool {
+	t.Helper()
+	g, _ := json.Marshal(decoded(t, got))
+	w, _ := json.Marshal(decoded(t, want))
+	return string(g) == string(w)
+}
+
+// The diagnostic of 'print cóut;' in a.rof, suggesting 'count'
+var testDiagnostic = Diagnostic{
+	Code:        CodeUndefinedVariable,
+	Message:     "Undefined variable 'cóut'.",
+	Span:        Span{Position{File: "a.rof", Line: 2, Column: 7, Offset: 21}, Position{File: "a.rof", Line: 2, Column: 12, Offset: 26}},
+	Label:       "not found",
+	Secondary:   []Label{{Span{Position{File: "a.rof", Line: 1, Column: 5, Offset: 4}, Position{File: "a.rof", Line: 1, Column: 10, Offset: 9}}, "similar"}},
+	Notes:       []string{"n"},
+	Help:        []string{"h"},
+	Suggestions: []Suggestion{{"use 'count'", Span{Position{File: "a.rof", Line: 2, Column: 7, Offset: 21}, Position{File: "a.rof", Line: 2, Column: 12, Offset: 26}}, "count"}},
+	Stack:       []Frame{{"outer", Position{File: "a.rof", Line: 3, Column: 1}, false}, {"clock", Position{File: "a.rof", Line: 4, Column: 2}, true}},
+}
+
+const testSourceA = "var count = 1;\nprint cóut;\n"
+
+func TestEncodeDiagnosticsJSON(t *testing.T) {
+	b, err := EncodeDiagnosticsJSON([]Diagnostic{testDiagnostic, {Severity: SeverityWarning, Message: "w"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Version     int
+		Diagnostics []map[string]interface{}
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != DiagnosticsVersion || len(got.Diagnostics) != 2 {
+		t.Fatalf("version %d with %d diagnostics, want %d with 2", got.Version, len(got.Diagnostics), DiagnosticsVersion)
+	}
+
+	position := func(line, column, offset int) jsonObject {
+		return jsonObject{"line": line, "column": column, "offset": offset}
+	}
+	want := map[string]interface{}{
+		"severity":    "error",
+		"code":        "E0401",
+		"message":     "Undefined variable 'cóut'.",
+		"span":        jsonObject{"file": "a.rof", "start": position(2, 7, 21), "end": position(2, 12, 26)},
+		"label":       "not found",
+		"secondary":   []jsonObject{{"message": "similar", "span": jsonObject{"file": "a.rof", "start": position(1, 5, 4), "end": position(1, 10, 9)}}},
+		"notes":       []string{"n"},
+		"help":        []string{"h"},
+		"suggestions": []jsonObject{{"message": "use 'count'", "replacement": "count", "span": jsonObject{"file": "a.rof", "start": position(2, 7, 21), "end": position(2, 12, 26)}}},
+		"stack": []jsonObject{
+			{"name": "clock", "file": "a.rof", "line": 4, "column": 2, "native": true},
+			{"name": "outer", "file": "a.rof", "line": 3, "column": 1, "native": false},
+		},
+	}
+	for key, value := range want {
+		if !sameJSON(t, got.Diagnostics[0][key], value) {
+			t.Errorf("%s is %v, want %v", key, decoded(t, got.Diagnostics[0][key]), decoded(t, value))
+		}
+	}
+
+	// Empty fields are null or empty lists, never missing.
+	empty := map[string]interface{}{
+		"severity": "warning", "code": "", "message": "w", "span": nil, "label": "",
+		"secondary": []string{}, "notes": []string{}, "help": []string{}, "suggestions": []string{}, "stack": []string{},
+	}
+	if !sameJSON(t, got.Diagnostics[1], empty) {
+		t.Errorf("empty diagnostic %v, want %v", got.Diagnostics[1], empty)
+	}
+
+	b, _ = EncodeDiagnosticsJSON(nil)
+	if !strings.Contains(string(b), `"diagnostics": []`) {
+		t.Errorf("no diagnostics gives %s", b)
+	}
+}
+
+func TestEncodeSARIF(t *testing.T) {
+	lint := Diagnostic{Severity: SeverityWarning, Code: RuleEmptyBlock, Message: "Empty block.",
+		Span: Span{Position{File: "b.rof", Line: 1, Column: 3}, Position{File: "b.rof", Line: 1, Column: 3}}}
+	info := Diagnostic{Severity: SeverityInfo, Message: "i"}
+	b, err := EncodeSARIF([]Diagnostic{testDiagnostic, lint, info}, "1.2.3", map[string]string{"a.rof": testSourceA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name, Version string
+					Rules         []map[string]interface{}
+				}
+			}
+			ColumnKind string
+			Results    []map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(b, &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("version %q with %d runs", log.Version, len(log.Runs))
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "rof" || run.Tool.Driver.Version != "1.2.3" || run.ColumnKind != "unicodeCodePoints" {
+		t.Errorf("driver %s %s, column kind %s", run.Tool.Driver.Name, run.Tool.Driver.Version, run.ColumnKind)
+	}
+
+	// Rules are sorted by code and described.
+	rules := run.Tool.Driver.Rules
+	if len(rules) != 2 || rules[0]["id"] != "E0401" || rules[1]["id"] != RuleEmptyBlock {
+		t.Fatalf("rules %v, want E0401 and %s", rules, RuleEmptyBlock)
+	}
+	if explanation, _ := Explain("E0401"); !sameJSON(t, rules[0]["fullDescription"], jsonObject{"text": explanation}) {
+		t.Errorf("E0401 is described as %v", rules[0]["fullDescription"])
+	}
+	if !sameJSON(t, rules[1]["shortDescription"], jsonObject{"text": LintRules[RuleEmptyBlock]}) {
+		t.Errorf("%s is described as %v", RuleEmptyBlock, rules[1]["shortDescription"])
+	}
+
+	region := func(line, start, endLine, end int) jsonObject {
+		return jsonObject{"startLine": line, "startColumn": start, "endLine": endLine, "endColumn": end}
+	}
+	location := func(file string, r jsonObject) jsonObject {
+		return jsonObject{"artifactLocation": jsonObject{"uri": file}, "region": r}
+	}
+	// Columns count code points in a.rof, 'cóut' ends at column 11 and
+	// not at byte column 12.
+	want := map[string]interface{}{
+		"level":     "error",
+		"ruleId":    "E0401",
+		"message":   jsonObject{"text": "Undefined variable 'cóut'.\nnote: n\nhelp: h"},
+		"locations": []jsonObject{{"physicalLocation": location("a.rof", region(2, 7, 2, 11))}},
+		"relatedLocations": []jsonObject{
+			{"id": 0, "physicalLocation": location("a.rof", region(1, 5, 1, 10)), "message": jsonObject{"text": "similar"}},
+		},
+		"fixes": []jsonObject{{
+			"description": jsonObject{"text": "use 'count'"},
+			"artifactChanges": []jsonObject{{
+				"artifactLocation": jsonObject{"uri": "a.rof"},
+				"replacements":     []jsonObject{{"deletedRegion": region(2, 7, 2, 11), "insertedContent": jsonObject{"text": "count"}}},
+			}},
+		}},
+		"stacks": []jsonObject{{"message": jsonObject{"text": "call stack"}, "frames": []jsonObject{
+			{"location": jsonObject{"physicalLocation": location("a.rof", region(4, 2, 4, 3)), "message": jsonObject{"text": "clock"}}},
+			{"location": jsonObject{"physicalLocation": location("a.rof", region(3, 1, 3, 2)), "message": jsonObject{"text": "outer"}}},
+		}}},
+	}
+	if !sameJSON(t, run.Results[0], want) {
+		t.Errorf("result\n%v\nwant\n%v", decoded(t, run.Results[0]), decoded(t, want))
+	}
+
+	// b.rof is unknown so its byte columns are kept, an empty span
+	// covers a character.
+	want = map[string]interface{}{
+		"level":     "warning",
+		"ruleId":    RuleEmptyBlock,
+		"message":   jsonObject{"text": "Empty block."},
+		"locations": []jsonObject{{"physicalLocation": location("b.rof", region(1, 3, 1, 4))}},
+	}
+	if !sameJSON(t, run.Results[1], want) {
+		t.Errorf("result\n%v\nwant\n%v", decoded(t, run.Results[1]), decoded(t, want))
+	}
+
+	want = map[string]interface{}{"level": "note", "message": jsonObject{"text": "i"}}
+	if !sameJSON(t, run.Results[2], want) {
+		t.Errorf("result\n%v\nwant\n%v", decoded(t, run.Results[2]), decoded(t, want))
+	}
+}
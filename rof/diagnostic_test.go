@@ -0,0 +1,139 @@
+package rof
+
+import (
+	"bytes"
+	"testing"
+)
+
+// span returns the span of the first occurrence of text on a line of
+// source, columns count bytes as the scanner's do
+func span(t *testing.T, source string, line int, text string) Span {
+	t.Helper()
+	lines := bytes.Split([]byte(source), []byte("\n"))
+	column := bytes.Index(lines[line-1], []byte(text))
+	if column < 0 {
+		t.Fatalf("%q not on line %d", text, line)
+	}
+	start := Position{Line: line, Column: column + 1}
+	end := Position{Line: line, Column: column + 1 + len(text)}
+	return Span{start, end}
+}
+
+func TestRenderer(t *testing.T) {
+	source := "var count = 1;\nprint cout;\n\n\tprint \"é\" - 1;\n"
+	tests := []struct {
+		name string
+		d    Diagnostic
+		want string
+	}{
+		{
+			name: "label",
+			d:    Diagnostic{Code: "E0401", Message: "Undefined variable 'cout'.", Span: span(t, source, 2, "cout"), Label: "not found"},
+			want: "error[E0401]: Undefined variable 'cout'.\n" +
+				" --> test.rof:2:7\n" +
+				"  |\n" +
+				"2 | print cout;\n" +
+				"  |       ^^^^ not found\n" +
+				"\n",
+		},
+		{
+			name: "secondary and help",
+			d: Diagnostic{
+				Message:   "Undefined variable 'cout'.",
+				Span:      span(t, source, 2, "cout"),
+				Secondary: []Label{{span(t, source, 1, "count"), "similar"}},
+				Help:      []string{"did you mean 'count'?"},
+				Notes:     []string{"variables are case sensitive"},
+			},
+			want: "error: Undefined variable 'cout'.\n" +
+				" --> test.rof:2:7\n" +
+				"  |\n" +
+				"1 | var count = 1;\n" +
+				"  |     ----- similar\n" +
+				"2 | print cout;\n" +
+				"  |       ^^^^\n" +
+				"  |\n" +
+				"  = note: variables are case sensitive\n" +
+				"  = help: did you mean 'count'?\n" +
+				"\n",
+		},
+		{
+			name: "lines left out",
+			d: Diagnostic{
+				Severity:  SeverityWarning,
+				Message:   "Far apart.",
+				Span:      span(t, source, 1, "count"),
+				Secondary: []Label{{span(t, source, 4, "-"), ""}},
+			},
+			want: "warning: Far apart.\n" +
+				" --> test.rof:1:5\n" +
+				"  |\n" +
+				"1 | var count = 1;\n" +
+				"  |     ^^^^^\n" +
+				"...\n" +
+				"4 | \tprint \"é\" - 1;\n" +
+				"  | \t          -\n" +
+				"\n",
+		},
+		{
+			name: "multi-byte",
+			d:    Diagnostic{Code: "E0302", Message: "Operands must be numbers", Span: span(t, source, 4, "\"é\"")},
+			want: "error[E0302]: Operands must be numbers\n" +
+				" --> test.rof:4:8\n" +
+				"  |\n" +
+				"4 | \tprint \"é\" - 1;\n" +
+				"  | \t      ^^^\n" +
+				"\n",
+		},
+		{
+			name: "multi-line span",
+			d:    Diagnostic{Message: "Statement.", Span: Span{Position{Line: 1, Column: 13}, Position{Line: 2, Column: 3}}},
+			want: "error: Statement.\n" +
+				" --> test.rof:1:13\n" +
+				"  |\n" +
+				"1 | var count = 1;\n" +
+				"  |             ^^\n" +
+				"\n",
+		},
+		{
+			name: "no span",
+			d:    Diagnostic{Severity: SeverityInfo, Message: "Nothing to run.", Help: []string{"add a statement"}},
+			want: "info: Nothing to run.\n" +
+				"--> test.rof\n" +
+				"= help: add a statement\n" +
+				"\n",
+		},
+		{
+			name: "other file",
+			d:    Diagnostic{Message: "Elsewhere.", Span: Span{Position{File: "other.rof", Line: 2, Column: 1}, Position{File: "other.rof", Line: 2, Column: 6}}},
+			want: "error: Elsewhere.\n" +
+				" --> other.rof:2:1\n" +
+				"  |\n" +
+				"2 | print cout;\n" +
+				"  | ^^^^^\n" +
+				"\n",
+		},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		NewRenderer(&out, "test.rof", source, false).Render(tt.d)
+		if got := out.String(); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRendererColor(t *testing.T) {
+	var out bytes.Buffer
+	d := Diagnostic{Severity: SeverityWarning, Code: "W1", Message: "Unused.", Span: span(t, "var x;", 1, "x")}
+	NewRenderer(&out, "test.rof", "var x;", true).Render(d)
+	want := ansiYellow + "warning[W1]" + ansiReset + ansiBold + ": Unused." + ansiReset + "\n" +
+		" " + ansiBlue + "-->" + ansiReset + " test.rof:1:5\n" +
+		ansiBlue + "  |" + ansiReset + "\n" +
+		ansiBlue + "1 |" + ansiReset + " var x;\n" +
+		ansiBlue + "  |" + ansiReset + "     " + ansiYellow + "^" + ansiReset + "\n" +
+		"\n"
+	if got := out.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -1,6 +1,8 @@
 package rof
 
 import (
+	"sort"
+
 	"github.com/reloonfire/rof-language/helpers"
 )
 
@@ -17,15 +19,21 @@ func NewEnv(enclosing *Environment) *Environment {
 }
 
 func (e *Environment) Get(name Token) interface{} {
-	if helpers.ContainsKey(e.Values, name.Lexeme) {
-		return e.Values[name.Lexeme]
+	if value, ok := e.lookup(name.Lexeme); ok {
+		return value
 	}
 
-	if e.Enclosing != nil {
-		return e.Enclosing.Get(name)
-	}
+	panic(e.undefined(name, "Undefined Variable '"+name.Lexeme+"'."))
+}
 
-	panic(&RuntimeError{name, "Undefined Variable '" + name.Lexeme + "'."})
+// lookup finds a name in the scope chain without failing
+func (e *Environment) lookup(name string) (interface{}, bool) {
+	for env := e; env != nil; env = env.Enclosing {
+		if helpers.ContainsKey(env.Values, name) {
+			return env.Values[name], true
+		}
+	}
+	return nil, false
 }
 
 func (e *Environment) Define(name string, value interface{}) {
@@ -33,16 +41,41 @@ func (e *Environment) Define(name string, value interface{}) {
 	//fmt.Println("[DEBUG] Env -> ", e.Values)
 }
 
-func (e *Environment) Assign(name Token, value interface{}) {
-	if helpers.ContainsKey(e.Values, name.Lexeme) {
-		e.Values[name.Lexeme] = value
-		return
+// Assign changes an existing variable and returns its previous value
+func (e *Environment) Assign(name Token, value interface{}) interface{} {
+	for env := e; env != nil; env = env.Enclosing {
+		if helpers.ContainsKey(env.Values, name.Lexeme) {
+			old := env.Values[name.Lexeme]
+			env.Values[name.Lexeme] = value
+			return old
+		}
 	}
 
-	if e.Enclosing != nil {
-		e.Enclosing.Assign(name, value)
-		return
+	panic(e.undefined(name, "Undefined variable '"+name.Lexeme+"'."))
+}
+
+// Names returns the sorted names defined in this scope only
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.Values))
+	for name := range e.Values {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	panic(&RuntimeError{name, "Undefined variable '" + name.Lexeme + "'."})
+// undefined returns the error of a name missing from the scope chain,
+// suggesting the names visible from this scope which are close to it
+func (e *Environment) undefined(name Token, message string) *RuntimeError {
+	var names []string
+	for env := e; env != nil; env = env.Enclosing {
+		names = append(names, env.Names()...)
+	}
+	return &RuntimeError{
+		Token:       name,
+		Message:     message,
+		Code:        CodeUndefinedVariable,
+		Label:       "not found in this scope",
+		Suggestions: suggestNames(name, names),
+	}
 }
@@ -2,15 +2,51 @@ package rof
 
 import "fmt"
 
+// RuntimeError - Error of a run at a token. Code, Label, Secondary,
+// Notes, Help and Suggestions are optional and only shown by the
+// diagnostics. Stack holds the calls in progress when it was raised, the
+// most recent last.
 type RuntimeError struct {
-	Token   Token
-	Message string
+	Token       Token
+	Message     string
+	Code        string
+	Label       string
+	Secondary   []Label
+	Notes       []string
+	Help        []string
+	Suggestions []Suggestion
+	Stack       []Frame
 }
 
 func (re *RuntimeError) Error() string {
 	return fmt.Sprintf("line #%d at '%v': '%s'", re.Token.Line, re.Token.Lexeme, re.Message)
 }
 
+func (re *RuntimeError) Diagnostic() Diagnostic {
+	return Diagnostic{
+		Code:        re.Code,
+		Message:     re.Message,
+		Span:        errorSpan(re.Token),
+		Label:       re.Label,
+		Secondary:   re.Secondary,
+		Notes:       re.Notes,
+		Help:        re.Help,
+		Suggestions: re.Suggestions,
+		Stack:       re.Stack,
+	}
+}
+
+// AssertionError - Failure of an assert builtin
+type AssertionError RuntimeError
+
+func (ae *AssertionError) Error() string {
+	return fmt.Sprintf("line #%d: %s", ae.Token.Line, ae.Message)
+}
+
+func (ae *AssertionError) Diagnostic() Diagnostic {
+	return (*RuntimeError)(ae).Diagnostic()
+}
+
 type ParseError RuntimeError
 
 func (pe *ParseError) Error() string {
@@ -20,3 +56,44 @@ func (pe *ParseError) Error() string {
 		return fmt.Sprintf("line #%d at '%v': %s", pe.Token.Line, pe.Token.Lexeme, pe.Message)
 	}
 }
+
+func (pe *ParseError) Diagnostic() Diagnostic {
+	return (*RuntimeError)(pe).Diagnostic()
+}
+
+// ScanError - Error found by the scanner, Span covers the faulty text
+type ScanError struct {
+	Span    Span
+	Message string
+	Code    string
+	Label   string
+	Help    []string
+}
+
+func (se *ScanError) Error() string {
+	return fmt.Sprintf("line #%d:%d: %s", se.Span.Start.Line, se.Span.Start.Column, se.Message)
+}
+
+func (se *ScanError) Diagnostic() Diagnostic {
+	return Diagnostic{Code: se.Code, Message: se.Message, Span: se.Span, Label: se.Label, Help: se.Help}
+}
+
+// ErrorList - Errors of a source that does not scan or parse, in source
+// order
+type ErrorList []error
+
+func (el ErrorList) Error() string {
+	if len(el) > 1 {
+		return fmt.Sprintf("%v (and %d more errors)", el[0], len(el)-1)
+	}
+	return el[0].Error()
+}
+
+// scanErrors returns the errors of a scanner as an ErrorList
+func scanErrors(sc *Scanner) ErrorList {
+	errs := make(ErrorList, len(sc.Errors))
+	for n, err := range sc.Errors {
+		errs[n] = err
+	}
+	return errs
+}
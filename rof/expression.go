@@ -1,77 +1,211 @@
+// Code generated by tool/generate_ast.go from ast.spec; DO NOT EDIT.
+
 package rof
 
 type Expr interface {
 	Expression() Expr
+	Accept(visitor ExprVisitor) interface{}
 }
 
 type Assign struct {
 	Name  Token
 	Value Expr
+	Span  Span
 }
 
-func (b Assign) Expression() Expr {
-	return b
+func (a Assign) Expression() Expr {
+	return a
+}
+
+func (a Assign) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitAssignExpr(a)
 }
 
 type Binary struct {
 	Left     Expr
 	Operator Token
 	Right    Expr
+	Span     Span
 }
 
 func (b Binary) Expression() Expr {
 	return b
 }
 
+func (b Binary) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitBinaryExpr(b)
+}
+
 type Grouping struct {
 	Expr
+	Span Span
 }
 
-func (b Grouping) Expression() Expr {
-	return b
+func (g Grouping) Expression() Expr {
+	return g
+}
+
+func (g Grouping) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitGroupingExpr(g)
 }
 
 type Literal struct {
 	Value interface{}
+	Span  Span
 }
 
 func (l Literal) Expression() Expr {
 	return l
 }
 
+func (l Literal) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitLiteralExpr(l)
+}
+
 type Unary struct {
 	Operator Token
 	Right    Expr
+	Span     Span
 }
 
 func (u Unary) Expression() Expr {
 	return u
 }
 
+func (u Unary) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitUnaryExpr(u)
+}
+
 type Variable struct {
 	Name Token
+	Span Span
 }
 
 func (v Variable) Expression() Expr {
 	return v
 }
 
+func (v Variable) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitVariableExpr(v)
+}
+
 type Logical struct {
 	Left     Expr
 	Operator Token
 	Right    Expr
+	Span     Span
 }
 
 func (l Logical) Expression() Expr {
 	return l
 }
 
+func (l Logical) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitLogicalExpr(l)
+}
+
 type Call struct {
 	Callee Expr
 	Paren  Token
 	Args   []Expr
+	Span   Span
 }
 
 func (c Call) Expression() Expr {
 	return c
 }
+
+func (c Call) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitCallExpr(c)
+}
+
+type Is struct {
+	Value   Expr
+	Keyword Token
+	Type    Token
+	Span    Span
+}
+
+func (i Is) Expression() Expr {
+	return i
+}
+
+func (i Is) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitIsExpr(i)
+}
+
+type Get struct {
+	Object Expr
+	Name   Token
+	Span   Span
+}
+
+func (g Get) Expression() Expr {
+	return g
+}
+
+func (g Get) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitGetExpr(g)
+}
+
+type With struct {
+	Object  Expr
+	Keyword Token
+	Names   []Token
+	Values  []Expr
+	Span    Span
+}
+
+func (w With) Expression() Expr {
+	return w
+}
+
+func (w With) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitWithExpr(w)
+}
+
+type Conditional struct {
+	Keyword    Token
+	Condition  Expr
+	ThenBranch Expr
+	ElseBranch Expr
+	Span       Span
+}
+
+func (c Conditional) Expression() Expr {
+	return c
+}
+
+func (c Conditional) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitConditionalExpr(c)
+}
+
+// Compound is a block used as an expression, Value is the trailing
+// expression without ';' and may be nil
+type Compound struct {
+	Statements []Stmt
+	Value      Expr
+	Span       Span
+}
+
+func (c Compound) Expression() Expr {
+	return c
+}
+
+func (c Compound) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitCompoundExpr(c)
+}
+
+type Loop struct {
+	Condition Expr
+	Body      Expr
+	Span      Span
+}
+
+func (l Loop) Expression() Expr {
+	return l
+}
+
+func (l Loop) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitLoopExpr(l)
+}
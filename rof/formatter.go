@@ -0,0 +1,277 @@
+package rof
+
+import (
+	"errors"
+	"strings"
+)
+
+// FormatIndent - Indentation used for each nesting level
+const FormatIndent = "    "
+
+// Format - Re-print source in the canonical style. Only whitespace
+// changes: comments are kept, statements go on their own line, blocks
+// are indented and at most one blank line is kept between lines.
+// Formatting an already formatted source returns it unchanged. A source
+// that does not scan or parse gets an ErrorList.
+func Format(source string) (string, error) {
+	sc := NewScanner(source)
+	sc.KeepTrivia = true
+	tokens := sc.Scan()
+	if sc.HadError {
+		return "", scanErrors(sc)
+	}
+
+	// The scanner drops characters it does not know, formatting would
+	// silently delete them from the file.
+	var rebuilt strings.Builder
+	for _, t := range tokens {
+		for _, tr := range t.Leading {
+			rebuilt.WriteString(tr.Text)
+		}
+		if t.TokenType != EOF {
+			rebuilt.WriteString(t.Lexeme)
+		}
+	}
+	if rebuilt.String() != source {
+		return "", errors.New("source contains characters the scanner does not recognize")
+	}
+
+	parser := Parser{Tokens: tokens, Quiet: true}
+	if _, errs := parser.Parse(); len(errs) > 0 {
+		return "", ErrorList(errs)
+	}
+
+	f := formatter{contexts: []formatContext{{}}, lineStart: true}
+	for n, t := range tokens {
+		f.token(tokens, n, t)
+	}
+	return f.out.String(), nil
+}
+
+type formatContext struct {
+	inline   bool // braces of 'with', kept on one line
+	indented bool // the block's lines are indented
+	parens   int
+}
+
+type formatter struct {
+	out      strings.Builder
+	contexts []formatContext
+	indent   int
+	// lineStart is true when nothing has been written on the current line
+	lineStart bool
+	// pendingBreak asks for a new line before the next token
+	pendingBreak bool
+	// midStatement makes a line broken by a comment a continuation line
+	midStatement bool
+	wroteAny     bool
+}
+
+func (f *formatter) token(tokens []Token, n int, t Token) {
+	f.trivia(t.Leading, n > 0, t.TokenType)
+	if t.TokenType == EOF {
+		if f.wroteAny && !f.lineStart {
+			f.out.WriteString("\n")
+		}
+		return
+	}
+
+	ctx := &f.contexts[len(f.contexts)-1]
+	if t.TokenType == RIGHT_BRACE && ctx.indented {
+		f.indent--
+		f.pendingBreak = true
+	}
+	if startsLine(tokens, n, ctx.inline) {
+		// A brace or 'else' moved to its own line by a comment lines up
+		// with its statement.
+		f.midStatement = false
+	}
+
+	if f.pendingBreak {
+		f.newline(0)
+	} else if !f.lineStart && (n == 0 || spaceBetween(tokens, n)) {
+		f.out.WriteString(" ")
+	}
+	if f.lineStart {
+		f.writeIndent()
+	}
+	f.write(t.Lexeme)
+	f.midStatement = true
+
+	switch t.TokenType {
+	case LEFT_PAREN:
+		ctx.parens++
+	case RIGHT_PAREN:
+		ctx.parens--
+	case SEMICOLON:
+		if ctx.parens == 0 && !ctx.inline {
+			f.pendingBreak = true
+			f.midStatement = false
+		}
+	case LEFT_BRACE:
+		inline := n > 0 && tokens[n-1].TokenType == WITH
+		indented := !inline && (tokens[n+1].TokenType != RIGHT_BRACE || hasComment(tokens[n+1].Leading))
+		f.contexts = append(f.contexts, formatContext{inline: inline, indented: indented})
+		if indented {
+			f.indent++
+			f.pendingBreak = true
+			f.midStatement = false
+		}
+	case RIGHT_BRACE:
+		if len(f.contexts) > 1 {
+			f.contexts = f.contexts[:len(f.contexts)-1]
+		}
+		if !ctx.inline && !continuesAfterBrace(tokens[n+1].TokenType) {
+			f.pendingBreak = true
+			f.midStatement = false
+		}
+	}
+}
+
+// trivia writes the comments before a token of type next and keeps one
+// blank line where the source had at least one
+func (f *formatter) trivia(leading []Trivia, afterToken bool, next TokenType) {
+	newlines := 0
+	afterComment := false
+	for _, tr := range leading {
+		switch tr.Kind {
+		case Newline:
+			newlines++
+			if afterComment {
+				f.pendingBreak = true
+			}
+		case LineComment, BlockComment:
+			if newlines == 0 && afterToken {
+				// Comment on the same line as the previous token.
+				f.out.WriteString(" ")
+				f.write(tr.Text)
+			} else {
+				f.newline(newlines)
+				f.writeIndent()
+				f.write(tr.Text)
+			}
+			if tr.Kind == LineComment {
+				f.pendingBreak = true
+			}
+			newlines = 0
+			afterToken = true
+			afterComment = true
+		}
+	}
+	if newlines > 1 && f.pendingBreak && next != RIGHT_BRACE && next != EOF {
+		f.blankLine()
+	}
+}
+
+// newline ends the current line, keeping a blank line when the source
+// had more than one newline
+func (f *formatter) newline(newlines int) {
+	if !f.wroteAny {
+		f.pendingBreak = false
+		return
+	}
+	if !f.lineStart {
+		f.out.WriteString("\n")
+		f.lineStart = true
+	}
+	if newlines > 1 {
+		f.blankLine()
+	}
+	f.pendingBreak = false
+}
+
+func (f *formatter) blankLine() {
+	if !f.wroteAny {
+		return
+	}
+	if !f.lineStart {
+		f.out.WriteString("\n")
+		f.lineStart = true
+	}
+	if s := f.out.String(); !strings.HasSuffix(s, "\n\n") && !strings.HasSuffix(s, "{\n") {
+		f.out.WriteString("\n")
+	}
+}
+
+func (f *formatter) writeIndent() {
+	indent := f.indent
+	if f.midStatement {
+		indent++
+	}
+	f.out.WriteString(strings.Repeat(FormatIndent, indent))
+}
+
+func (f *formatter) write(text string) {
+	f.out.WriteString(text)
+	f.lineStart = false
+	f.wroteAny = true
+}
+
+// spaceBetween decides the spacing between tokens[n-1] and tokens[n]
+func spaceBetween(tokens []Token, n int) bool {
+	prev, cur := tokens[n-1].TokenType, tokens[n].TokenType
+	switch cur {
+	case SEMICOLON, COMMA, RIGHT_PAREN, DOT, COLON:
+		return false
+	case LEFT_PAREN:
+		if prev == IDENTIFIER || prev == RIGHT_PAREN || prev == THIS || prev == SUPER {
+			return false
+		}
+	case RIGHT_BRACE:
+		return prev != LEFT_BRACE
+	}
+	switch prev {
+	case LEFT_PAREN, DOT, BANG:
+		return false
+	case MINUS:
+		return n >= 2 && endsOperand(tokens[n-2].TokenType)
+	}
+	return true
+}
+
+// endsOperand reports whether a token can end an operand, so that a
+// following '-' is binary
+func endsOperand(t TokenType) bool {
+	switch t {
+	case IDENTIFIER, NUMBER, STRING, TRUE, FALSE, NIL, THIS, RIGHT_PAREN, RIGHT_BRACE:
+		return true
+	}
+	return false
+}
+
+// startsLine reports whether tokens[n] is placed like the start of a
+// statement rather than as a continuation line. inline tells whether the
+// enclosing braces are those of a 'with'.
+func startsLine(tokens []Token, n int, inline bool) bool {
+	switch tokens[n].TokenType {
+	case LEFT_BRACE:
+		return n == 0 || tokens[n-1].TokenType != WITH
+	case RIGHT_BRACE:
+		return !inline
+	case ELSE:
+		return true
+	}
+	return false
+}
+
+// hasComment reports whether trivia holds a comment
+func hasComment(trivia []Trivia) bool {
+	for _, tr := range trivia {
+		if tr.Kind == LineComment || tr.Kind == BlockComment {
+			return true
+		}
+	}
+	return false
+}
+
+// continuesAfterBrace reports whether t continues the line after a
+// closing brace, like '} else' or the ';' after a block expression
+func continuesAfterBrace(t TokenType) bool {
+	switch t {
+	case ELSE, SEMICOLON, COMMA, RIGHT_PAREN, DOT, COLON, EQUAL,
+		PLUS, MINUS, STAR, SLASH, EQUAL_EQUAL, BANG_EQUAL,
+		GREATER, GREATER_EQUAL, LESS, LESS_EQUAL, AND, OR, IS, WITH:
+		return true
+	}
+	return false
+}
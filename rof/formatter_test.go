@@ -0,0 +1,64 @@
+package rof
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"print 1;", "print 1;\n"},
+		{"var a=1+2;print a;", "var a = 1 + 2;\nprint a;\n"},
+		{"if (a) { print 1; } else { print 2; }", "if (a) {\n    print 1;\n} else {\n    print 2;\n}\n"},
+		{"while(i<3){i=i+1;}", "while (i < 3) {\n    i = i + 1;\n}\n"},
+		{"print -a - -1;", "print -a - -1;\n"},
+		{"print f(a, b).c;", "print f(a, b).c;\n"},
+		{"var p = q with { x: 1 };", "var p = q with { x: 1 };\n"},
+		{"print 1; // one\n\n\n// two\nprint 2;", "print 1; // one\n\n// two\nprint 2;\n"},
+		{"/* a /* nested */ b */ print 1;", "/* a /* nested */ b */ print 1;\n"},
+		{"print { if (c) 1 else 2 };", "print {\n    if (c) 1 else 2\n};\n"},
+		{"{}", "{}\n"},
+		{"{ // c\n}", "{ // c\n}\n"},
+		{"{\n// c\n}", "{\n    // c\n}\n"},
+		{"if (a) // x\n{ print 1; }", "if (a) // x\n{\n    print 1;\n}\n"},
+		{"if (a) { print 1; } // x\nelse { print 2; }", "if (a) {\n    print 1;\n} // x\nelse {\n    print 2;\n}\n"},
+		{"var a = 1 + // x\n2;", "var a = 1 + // x\n    2;\n"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := Format(tt.source)
+		if err != nil {
+			t.Errorf("%q: %v", tt.source, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got\n%s\nwant\n%s", tt.source, got, tt.want)
+		}
+		again, err := Format(got)
+		if err != nil || again != got {
+			t.Errorf("%q: formatting twice gives\n%s\n%v", tt.source, again, err)
+		}
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		errors int
+	}{
+		{"print 1", 1},
+		{"var = 1; print (;", 2},
+		{"\"open", 1},
+		{"print 1 # 2;", 1},
+	}
+	for _, tt := range tests {
+		got, err := Format(tt.source)
+		if err == nil || got != "" {
+			t.Errorf("%q: got %q, want an error", tt.source, got)
+			continue
+		}
+		if errs, ok := err.(ErrorList); !ok || len(errs) != tt.errors {
+			t.Errorf("%q: error %v, want %d errors", tt.source, err, tt.errors)
+		}
+	}
+}
@@ -2,21 +2,26 @@ package rof
 
 import "fmt"
 
-type LoxCallable func(Interpreter, []Expr) interface{}
+type LoxCallable func(Interpreter, []interface{}) interface{}
 
 type Callable interface {
 	Arity() int
-	Call(i Interpreter, args []Expr) interface{}
+	Name() string
+	Call(i Interpreter, args []interface{}) interface{}
 }
 
+// NativeFunction - Builtin function. A variadic one takes at least A
+// arguments.
 type NativeFunction struct {
 	Callable
-	NativeCall LoxCallable
-	A          int
+	FunctionName string
+	NativeCall   LoxCallable
+	A            int
+	Variadic     bool
 }
 
 // Call is the operation that executes a builtin function
-func (n NativeFunction) Call(i Interpreter, arguments []Expr) interface{} {
+func (n NativeFunction) Call(i Interpreter, arguments []interface{}) interface{} {
 	return n.NativeCall(i, arguments)
 }
 
@@ -25,7 +30,37 @@ func (n NativeFunction) Arity() int {
 	return n.A
 }
 
+// checkArity panics with an arity error when function cannot be called
+// with args, at token
+func checkArity(token Token, function Callable, args []interface{}) {
+	least := ""
+	if native, ok := function.(NativeFunction); ok && native.Variadic {
+		if len(args) >= native.A {
+			return
+		}
+		least = "at least "
+	} else if len(args) == function.Arity() {
+		return
+	}
+	arguments := "arguments"
+	if function.Arity() == 1 {
+		arguments = "argument"
+	}
+	panic(&RuntimeError{
+		Token:   token,
+		Message: fmt.Sprintf("Expected %s%d %s but got %d.", least, function.Arity(), arguments, len(args)),
+		Code:    CodeArityMismatch,
+		Label:   fmt.Sprintf("called with %d", len(args)),
+		Notes:   []string{fmt.Sprintf("%s takes %s%d %s", function.Name(), least, function.Arity(), arguments)},
+	})
+}
+
+// Name returns the name the native function is defined with
+func (n NativeFunction) Name() string {
+	return n.FunctionName
+}
+
 // String returns the name of the native function
 func (n NativeFunction) String() string {
-	return fmt.Sprintf("<native/%p>", n.NativeCall)
+	return fmt.Sprintf("<native fn %s>", n.FunctionName)
 }
@@ -0,0 +1,4 @@
+package rof
+
+// The syntax tree is described in ast.spec
+//go:generate go run ../tool/generate_ast.go .
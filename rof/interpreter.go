@@ -2,20 +2,32 @@ package rof
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"reflect"
-	"time"
 )
 
 type Interpreter struct {
 	Globals *Environment
 	Env     *Environment
+	// Out receives the output of print, os.Stdout when nil
+	Out io.Writer
+	// Debugger is notified of the statements being run when set
+	Debugger Debugger
+	// Tracer receives every statement, definition, assignment and call
+	// when set
+	Tracer Tracer
+	stack  *callStack
 }
 
 func NewInterpreter() Interpreter {
 	var i Interpreter
 	i.Globals = NewEnv(nil)
 	i.Env = i.Globals
-	i.Globals.Define("clock", NativeFunction{NativeCall: func(Interpreter, []Expr) interface{} { return float64(time.Now().Second()) }, A: 0})
+	i.stack = &callStack{}
+	for _, native := range builtins {
+		i.Globals.Define(native.FunctionName, native)
+	}
 	return i
 }
 
@@ -23,7 +35,7 @@ func (i Interpreter) Interpret(stmts []Stmt) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = r.(error)
+			err = recovered(r)
 		}
 	}()
 
@@ -34,50 +46,42 @@ func (i Interpreter) Interpret(stmts []Stmt) (err error) {
 	return nil
 }
 
-func (i Interpreter) evaluate(expr Expr) interface{} {
-	switch t := expr.(type) {
-	case Binary:
-		return i.BinaryExpr(t)
-	case Grouping:
-		return i.GroupingExpr(t)
-	case Literal:
-		return i.LiteralExpr(t)
-	case Unary:
-		return i.UnaryExpr(t)
-	case Variable:
-		return i.VariableExpr(t)
-	case Assign:
-		return i.AssignExpr(t)
-	case Logical:
-		return i.LogicalExpr(t)
-	case Call:
-		return i.CallExpr(t)
-	default:
-		fmt.Println("[ERROR] Type -> ", reflect.TypeOf(t))
-		return nil
+// Evaluate - Evaluate a single expression in the current environment
+func (i Interpreter) Evaluate(expr Expr) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(r)
+		}
+	}()
+
+	return i.evaluate(expr), nil
+}
+
+// recovered returns the error of a recovered panic, other values are
+// wrapped so that they are still reported
+func recovered(r interface{}) error {
+	if err, ok := r.(error); ok {
+		return err
 	}
+	return fmt.Errorf("%v", r)
+}
+
+func (i Interpreter) evaluate(expr Expr) interface{} {
+	return expr.Accept(i)
 }
 
 func (i Interpreter) execute(stmt Stmt) {
-	switch t := stmt.(type) {
-	case Expression:
-		i.ExprStmt(t)
-	case Print:
-		i.PrintStmt(t)
-	case Var:
-		i.VarStmt(t)
-	case Block:
-		i.BlockStmt(t)
-	case If:
-		i.IfStmt(t)
-	case While:
-		i.WhileStmt(t)
-	default:
-		fmt.Println("[ERROR] Type -> ", reflect.TypeOf(t))
+	if i.Debugger != nil {
+		i.Debugger.Statement(i, stmt)
+	}
+	if _, ok := stmt.(Block); !ok && i.Tracer != nil {
+		i.Tracer.Statement(stmt, i.depth())
 	}
+
+	stmt.Accept(i)
 }
 
-func (i Interpreter) BinaryExpr(expr Binary) interface{} {
+func (i Interpreter) VisitBinaryExpr(expr Binary) interface{} {
 	right := i.evaluate(expr.Right)
 	left := i.evaluate(expr.Left)
 	//fmt.Println("[DEBUG] BinaryExpr Called -> ", expr, "\n\n	RIGHT -> ", right, "\n	LEFT -> ", left, "\n	Operator -> ", expr.Operator)
@@ -105,12 +109,11 @@ func (i Interpreter) BinaryExpr(expr Binary) interface{} {
 		l, r := i.checkNumberOperands(expr.Operator, left, right)
 		return l * r
 	case PLUS:
-		switch l := left.(type) {
-		case float64:
-			return l + right.(float64)
-		case string:
+		if l, ok := left.(string); ok {
 			return l + fmt.Sprint(right)
 		}
+		l, r := i.checkNumberOperands(expr.Operator, left, right)
+		return l + r
 
 	case EQUAL_EQUAL:
 		return i.isEqual(left, right)
@@ -123,16 +126,16 @@ func (i Interpreter) BinaryExpr(expr Binary) interface{} {
 
 }
 
-func (i Interpreter) GroupingExpr(expr Grouping) interface{} {
+func (i Interpreter) VisitGroupingExpr(expr Grouping) interface{} {
 	return i.evaluate(expr.Expr)
 }
 
-func (i Interpreter) LiteralExpr(expr Literal) interface{} {
+func (i Interpreter) VisitLiteralExpr(expr Literal) interface{} {
 	return expr.Value
 }
 
-func (i Interpreter) UnaryExpr(expr Unary) interface{} {
-	right := i.evaluate(expr)
+func (i Interpreter) VisitUnaryExpr(expr Unary) interface{} {
+	right := i.evaluate(expr.Right)
 
 	switch expr.Operator.TokenType {
 	case BANG:
@@ -147,18 +150,21 @@ func (i Interpreter) UnaryExpr(expr Unary) interface{} {
 
 }
 
-func (i Interpreter) VariableExpr(expr Variable) interface{} {
+func (i Interpreter) VisitVariableExpr(expr Variable) interface{} {
 	return i.Env.Get(expr.Name)
 }
 
-func (i Interpreter) AssignExpr(expr Assign) interface{} {
+func (i Interpreter) VisitAssignExpr(expr Assign) interface{} {
 	value := i.evaluate(expr.Value)
 
-	i.Env.Assign(expr.Name, value)
+	old := i.Env.Assign(expr.Name, value)
+	if i.Tracer != nil {
+		i.Tracer.Assign(expr.Name, old, value, i.depth())
+	}
 	return value
 }
 
-func (i Interpreter) LogicalExpr(expr Logical) interface{} {
+func (i Interpreter) VisitLogicalExpr(expr Logical) interface{} {
 	left := i.evaluate(expr.Left)
 
 	if expr.Operator.TokenType == OR {
@@ -173,49 +179,154 @@ func (i Interpreter) LogicalExpr(expr Logical) interface{} {
 	return i.evaluate(expr.Right)
 }
 
-func (i Interpreter) CallExpr(expr Call) interface{} {
+func (i Interpreter) VisitCallExpr(expr Call) interface{} {
 	callee := i.evaluate(expr.Callee)
+	// Errors raised by builtins have no position, use the call's.
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case *RuntimeError:
+				if t.Token.Line == 0 {
+					t.Token = expr.Paren
+				}
+			case *AssertionError:
+				if t.Token.Line == 0 {
+					t.Token = expr.Paren
+				}
+			}
+			panic(r)
+		}
+	}()
 
-	var args []Expr
+	var args []interface{}
 	for _, arg := range expr.Args {
-		args = append(args, i.evaluate(arg).(Expr))
+		args = append(args, i.evaluate(arg))
 	}
 
 	if _, ok := callee.(Callable); !ok {
-		panic(&RuntimeError{expr.Paren, "Can only call functions and classes."})
+		panic(&RuntimeError{Token: expr.Paren, Message: "Can only call functions and classes.", Code: CodeNotCallable, Label: TypeOf(callee) + " is not callable"})
 	}
 
 	function, _ := callee.(Callable)
+	if i.Tracer != nil {
+		i.Tracer.Call(expr.Paren, function, args, i.depth())
+	}
+
+	checkArity(expr.Paren, function, args)
+
+	result := i.call(expr, function, args)
+	if i.Tracer != nil {
+		i.Tracer.Return(expr.Paren, function, result, i.depth())
+	}
+	return result
+}
+
+func (i Interpreter) VisitIsExpr(expr Is) interface{} {
+	value := i.evaluate(expr.Value)
+	if typeNames[expr.Type.Lexeme] {
+		return TypeOf(value) == expr.Type.Lexeme
+	}
+	named, _ := i.Env.lookup(expr.Type.Lexeme)
+	if t, ok := named.(*RecordType); ok {
+		instance, ok := value.(*RecordInstance)
+		return ok && instance.Type == t
+	}
+	panic(&RuntimeError{
+		Token:   expr.Type,
+		Message: "Unknown type '" + expr.Type.Lexeme + "'.",
+		Code:    CodeUnknownType,
+		Help:    []string{"use nil, bool, number, string, function or the name of a record"},
+	})
+}
+
+func (i Interpreter) VisitGetExpr(expr Get) interface{} {
+	object := i.evaluate(expr.Object)
+	if instance, ok := object.(*RecordInstance); ok {
+		return instance.Get(expr.Name)
+	}
+
+	panic(&RuntimeError{Token: expr.Name, Message: "Only records have fields.", Code: CodeNotARecord, Label: "field of a " + TypeOf(object)})
+}
+
+func (i Interpreter) VisitWithExpr(expr With) interface{} {
+	object := i.evaluate(expr.Object)
+	instance, ok := object.(*RecordInstance)
+	if !ok {
+		panic(&RuntimeError{Token: expr.Keyword, Message: "Only records can be copied with 'with'.", Code: CodeNotARecord, Label: "copy of a " + TypeOf(object)})
+	}
 
-	if len(args) != function.Arity() {
-		panic(&RuntimeError{expr.Paren, "Expected " +
-			string(function.Arity()) + " arguments but got " +
-			string(len(args)) + "."})
+	var values []interface{}
+	for _, v := range expr.Values {
+		values = append(values, i.evaluate(v))
 	}
+	return instance.With(expr.Names, values)
+}
 
-	return function.Call(i, args)
+func (i Interpreter) VisitConditionalExpr(expr Conditional) interface{} {
+	if i.isTruthy(i.evaluate(expr.Condition)) {
+		return i.evaluate(expr.ThenBranch)
+	}
+	return i.evaluate(expr.ElseBranch)
 }
 
-func (i Interpreter) ExprStmt(stmt Expression) {
+func (i Interpreter) VisitCompoundExpr(expr Compound) interface{} {
+	previous := i.Env
+
+	i.Env = NewEnv(previous)
+	defer func() { i.Env = previous }()
+	if i.Debugger != nil {
+		i.Debugger.Enter(i, expr)
+		defer i.Debugger.Leave(i, expr)
+	}
+	for _, s := range expr.Statements {
+		i.execute(s)
+	}
+	if expr.Value == nil {
+		return nil
+	}
+	return i.evaluate(expr.Value)
+}
+
+// VisitLoopExpr evaluates to the value of the last iteration, nil if the
+// body never ran
+func (i Interpreter) VisitLoopExpr(expr Loop) interface{} {
+	var value interface{}
+	for i.isTruthy(i.evaluate(expr.Condition)) {
+		value = i.evaluate(expr.Body)
+	}
+	return value
+}
+
+func (i Interpreter) VisitExpressionStmt(stmt Expression) interface{} {
 	i.evaluate(stmt.Expr)
+	return nil
 }
 
-func (i Interpreter) PrintStmt(stmt Print) {
+func (i Interpreter) VisitPrintStmt(stmt Print) interface{} {
 	//fmt.Println("[DEBUG] Print Called ->", stmt)
 	value := i.evaluate(stmt.Expr)
-	fmt.Println(Stringify(value))
+	out := i.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintln(out, Stringify(value))
+	return nil
 }
 
-func (i Interpreter) VarStmt(stmt Var) {
+func (i Interpreter) VisitVarStmt(stmt Var) interface{} {
 	var value interface{}
 	if stmt.Initializer != nil {
 		value = i.evaluate(stmt.Initializer)
 	}
 	//fmt.Println("[DEBUG] Create var ", stmt.Name.Lexeme, " -> ", value)
 	i.Env.Define(stmt.Name.Lexeme, value)
+	if i.Tracer != nil {
+		i.Tracer.Define(stmt.Name, value, i.depth())
+	}
+	return nil
 }
 
-func (i Interpreter) BlockStmt(stmt Block) {
+func (i Interpreter) VisitBlockStmt(stmt Block) interface{} {
 	previous := i.Env
 
 	i.Env = NewEnv(previous)
@@ -223,24 +334,58 @@ func (i Interpreter) BlockStmt(stmt Block) {
 	for _, s := range stmt.Statements {
 		i.execute(s)
 	}
+	return nil
+}
+
+func (i Interpreter) VisitRecordStmt(stmt Record) interface{} {
+	var fields []string
+	for _, f := range stmt.Fields {
+		fields = append(fields, f.Lexeme)
+	}
+	record := &RecordType{stmt.Name.Lexeme, fields}
+	i.Env.Define(stmt.Name.Lexeme, record)
+	if i.Tracer != nil {
+		i.Tracer.Define(stmt.Name, record, i.depth())
+	}
+	return nil
 }
 
-func (i Interpreter) IfStmt(stmt If) {
+func (i Interpreter) VisitIfStmt(stmt If) interface{} {
 	if i.isTruthy(i.evaluate(stmt.Condition)) {
 		i.execute(stmt.ThenBranch)
 	} else if stmt.ElseBranch != nil {
 		i.execute(stmt.ElseBranch)
 	}
+	return nil
 }
 
-func (i Interpreter) WhileStmt(stmt While) {
+func (i Interpreter) VisitWhileStmt(stmt While) interface{} {
 	for i.isTruthy(i.evaluate(stmt.Condition)) {
 		i.execute(stmt.Body)
 	}
+	return nil
+}
+
+// VisitTestStmt does nothing, tests are only run by RunTests
+func (i Interpreter) VisitTestStmt(stmt Test) interface{} {
+	return nil
+}
+
+func (i Interpreter) VisitEmptyStmt(stmt Empty) interface{} {
+	return nil
 }
 
 // Helper
 
+// depth is the number of scopes opened since the globals
+func (i Interpreter) depth() int {
+	n := 0
+	for env := i.Env; env != i.Globals && env != nil; env = env.Enclosing {
+		n++
+	}
+	return n
+}
+
 func (i Interpreter) isTruthy(obj interface{}) bool {
 	if obj == nil {
 		return false
@@ -252,7 +397,7 @@ func (i Interpreter) isTruthy(obj interface{}) bool {
 }
 
 func (i Interpreter) isEqual(obj1, obj2 interface{}) bool {
-	return obj1 == obj2
+	return valuesEqual(obj1, obj2)
 }
 
 func (i Interpreter) checkNumberOperand(operator Token, operand interface{}) float64 {
@@ -266,7 +411,7 @@ func (i Interpreter) checkNumberOperand(operator Token, operand interface{}) flo
 	}
 
 	if !ok {
-		panic(&RuntimeError{operator, "Operand must be number"})
+		panic(&RuntimeError{Token: operator, Message: "Operand must be number", Code: CodeNotANumber, Label: "applied to a " + TypeOf(operand)})
 	}
 
 	return n
@@ -286,7 +431,7 @@ func (i Interpreter) checkNumberOperands(operator Token, left, right interface{}
 		n2 = t
 	}
 	if !ok1 || !ok2 {
-		panic(&RuntimeError{operator, "Operands must be numbers"})
+		panic(&RuntimeError{Token: operator, Message: "Operands must be numbers", Code: CodeNotANumber, Label: "applied to a " + TypeOf(left) + " and a " + TypeOf(right)})
 	}
 
 	return n1, n2
@@ -306,6 +451,8 @@ func Stringify(obj interface{}) string {
 		return fmt.Sprintf("%v", t)
 	case bool:
 		return fmt.Sprintf("%v", t)
+	case fmt.Stringer:
+		return t.String()
 	default:
 		return "nil"
 	}
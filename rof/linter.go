@@ -0,0 +1,453 @@
+package rof
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Lint rules
+const (
+	RuleUnusedVariable    = "unused-variable"
+	RuleShadowing         = "shadowing"
+	RuleAssignInCondition = "assign-in-condition"
+	RuleSelfComparison    = "self-comparison"
+	RuleConstantCondition = "constant-condition"
+	RuleEmptyBlock        = "empty-block"
+)
+
+// LintRules - Every rule with a short description
+var LintRules = map[string]string{
+	RuleUnusedVariable:    "global variables that are declared but never read",
+	RuleShadowing:         "variables hiding a variable of an outer scope",
+	RuleAssignInCondition: "assignments used as the condition of if and while",
+	RuleSelfComparison:    "comparisons of a value with itself",
+	RuleConstantCondition: "conditions that are always true or always false",
+	RuleEmptyBlock:        "blocks without statements",
+}
+
+// LintWarning - Problem found by the Linter, Span is zero when the
+// code it is about has no position
+type LintWarning struct {
+	Rule    string
+	Line    int
+	Message string
+	Span    Span
+}
+
+func (w *LintWarning) Error() string {
+	return fmt.Sprintf("line #%d: %s [%s]", w.Line, w.Message, w.Rule)
+}
+
+// Diagnostic returns the warning coded by its rule, at the start of its
+// line when it has no span
+func (w *LintWarning) Diagnostic() Diagnostic {
+	span := w.Span
+	if span.IsZero() {
+		start := Position{Line: w.Line, Column: 1}
+		span = Span{start, start}
+	}
+	return Diagnostic{Severity: SeverityWarning, Code: w.Rule, Message: w.Message, Span: span}
+}
+
+// LintConfig - Rules enabled for a run, rules missing from the map are
+// enabled. It is read from JSON like {"rules": {"shadowing": false}}.
+type LintConfig struct {
+	Rules map[string]bool `json:"rules"`
+}
+
+// LoadLintConfig - Read a configuration file, unknown rules are an error
+func LoadLintConfig(path string) (LintConfig, error) {
+	var config LintConfig
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+	for rule := range config.Rules {
+		if _, ok := LintRules[rule]; !ok {
+			return config, fmt.Errorf("%s: unknown rule %q", path, rule)
+		}
+	}
+	return config, nil
+}
+
+func (c LintConfig) enabled(rule string) bool {
+	on, ok := c.Rules[rule]
+	return !ok || on
+}
+
+type lintVar struct {
+	name Token
+	used bool
+}
+
+type lintScope struct {
+	enclosing *lintScope
+	vars      map[string]*lintVar
+	order     []*lintVar
+}
+
+// Linter - Static pass looking for suspicious code
+type Linter struct {
+	Config   LintConfig
+	Warnings []*LintWarning
+	scope    *lintScope
+	// line of the statement being linted, for nodes without tokens
+	line    int
+	printer ASTPrinter
+}
+
+// Lint - Scan, parse and lint source, an ErrorList is returned when it
+// does not scan or parse. Warnings on a line can be
+// suppressed with a '// rof:ignore rule...' comment at the end of the
+// line or on the line before, without rules every warning is ignored.
+func Lint(source string, config LintConfig) ([]*LintWarning, error) {
+	sc := NewScanner(source)
+	sc.KeepTrivia = true
+	tokens := sc.Scan()
+	if sc.HadError {
+		return nil, scanErrors(sc)
+	}
+	parser := Parser{Tokens: tokens, Quiet: true}
+	stmts, errs := parser.Parse()
+	if len(errs) > 0 {
+		return nil, ErrorList(errs)
+	}
+
+	l := &Linter{Config: config}
+	ignored := ignoredLines(tokens)
+	var warnings []*LintWarning
+	for _, w := range l.Lint(stmts) {
+		if rules, ok := ignored[w.Line]; ok && (len(rules) == 0 || rules[w.Rule]) {
+			continue
+		}
+		warnings = append(warnings, w)
+	}
+	return warnings, nil
+}
+
+// Lint - Run the enabled rules over the statements
+func (l *Linter) Lint(stmts []Stmt) []*LintWarning {
+	l.scope = &lintScope{vars: make(map[string]*lintVar)}
+	for _, stmt := range stmts {
+		l.stmt(stmt)
+	}
+	l.endScope()
+
+	sort.SliceStable(l.Warnings, func(a, b int) bool {
+		return l.Warnings[a].Line < l.Warnings[b].Line
+	})
+	return l.Warnings
+}
+
+func (l *Linter) stmt(stmt Stmt) {
+	if line := StmtLine(stmt); line != 0 {
+		l.line = line
+	}
+	stmt.Accept(l)
+}
+
+func (l *Linter) expr(expr Expr) {
+	expr.Accept(l)
+}
+
+func (l *Linter) VisitExpressionStmt(stmt Expression) interface{} {
+	l.expr(stmt.Expr)
+	return nil
+}
+
+func (l *Linter) VisitPrintStmt(stmt Print) interface{} {
+	l.expr(stmt.Expr)
+	return nil
+}
+
+func (l *Linter) VisitVarStmt(stmt Var) interface{} {
+	if stmt.Initializer != nil {
+		l.expr(stmt.Initializer)
+	}
+	l.declare(stmt.Name)
+	return nil
+}
+
+func (l *Linter) VisitRecordStmt(stmt Record) interface{} {
+	l.declare(stmt.Name)
+	return nil
+}
+
+func (l *Linter) VisitBlockStmt(stmt Block) interface{} {
+	if len(stmt.Statements) == 0 {
+		l.warn(RuleEmptyBlock, stmt.Span, "Empty block.")
+	}
+	l.block(stmt.Statements)
+	return nil
+}
+
+func (l *Linter) VisitIfStmt(stmt If) interface{} {
+	l.condition(stmt.Condition)
+	l.expr(stmt.Condition)
+	l.stmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		l.stmt(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (l *Linter) VisitWhileStmt(stmt While) interface{} {
+	l.condition(stmt.Condition)
+	l.expr(stmt.Condition)
+	l.stmt(stmt.Body)
+	return nil
+}
+
+func (l *Linter) VisitTestStmt(stmt Test) interface{} {
+	l.block(stmt.Body)
+	return nil
+}
+
+func (l *Linter) VisitEmptyStmt(stmt Empty) interface{} {
+	return nil
+}
+
+func (l *Linter) VisitBinaryExpr(expr Binary) interface{} {
+	switch expr.Operator.TokenType {
+	case EQUAL_EQUAL, BANG_EQUAL, GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+		left, right := l.printer.PrintExpr(expr.Left), l.printer.PrintExpr(expr.Right)
+		// Calls may return a different value each time.
+		if left == right && !strings.Contains(left, "(call") {
+			l.warn(RuleSelfComparison, expr.Span, "Comparison of '"+left+"' with itself.")
+		}
+	}
+	l.expr(expr.Left)
+	l.expr(expr.Right)
+	return nil
+}
+
+func (l *Linter) VisitGroupingExpr(expr Grouping) interface{} {
+	l.expr(expr.Expr)
+	return nil
+}
+
+func (l *Linter) VisitLiteralExpr(expr Literal) interface{} {
+	return nil
+}
+
+func (l *Linter) VisitUnaryExpr(expr Unary) interface{} {
+	l.expr(expr.Right)
+	return nil
+}
+
+func (l *Linter) VisitVariableExpr(expr Variable) interface{} {
+	if v := l.lookup(expr.Name.Lexeme); v != nil {
+		v.used = true
+	}
+	return nil
+}
+
+func (l *Linter) VisitAssignExpr(expr Assign) interface{} {
+	l.expr(expr.Value)
+	return nil
+}
+
+func (l *Linter) VisitLogicalExpr(expr Logical) interface{} {
+	l.expr(expr.Left)
+	l.expr(expr.Right)
+	return nil
+}
+
+func (l *Linter) VisitCallExpr(expr Call) interface{} {
+	l.expr(expr.Callee)
+	for _, a := range expr.Args {
+		l.expr(a)
+	}
+	return nil
+}
+
+func (l *Linter) VisitIsExpr(expr Is) interface{} {
+	l.expr(expr.Value)
+	if v := l.lookup(expr.Type.Lexeme); v != nil {
+		v.used = true
+	}
+	return nil
+}
+
+func (l *Linter) VisitGetExpr(expr Get) interface{} {
+	l.expr(expr.Object)
+	return nil
+}
+
+func (l *Linter) VisitWithExpr(expr With) interface{} {
+	l.expr(expr.Object)
+	for _, v := range expr.Values {
+		l.expr(v)
+	}
+	return nil
+}
+
+func (l *Linter) VisitConditionalExpr(expr Conditional) interface{} {
+	l.condition(expr.Condition)
+	l.expr(expr.Condition)
+	l.expr(expr.ThenBranch)
+	l.expr(expr.ElseBranch)
+	return nil
+}
+
+func (l *Linter) VisitCompoundExpr(expr Compound) interface{} {
+	l.beginScope()
+	for _, s := range expr.Statements {
+		l.stmt(s)
+	}
+	if expr.Value != nil {
+		l.expr(expr.Value)
+	}
+	l.endScope()
+	return nil
+}
+
+func (l *Linter) VisitLoopExpr(expr Loop) interface{} {
+	l.condition(expr.Condition)
+	l.expr(expr.Condition)
+	l.expr(expr.Body)
+	return nil
+}
+
+// condition checks the condition of an if or a loop, this includes the
+// 'true' the parser inserts for 'for (;;)'
+func (l *Linter) condition(condition Expr) {
+	if _, ok := condition.(Assign); ok {
+		l.warn(RuleAssignInCondition, ExprSpan(condition), "Assignment used as a condition, did you mean '=='?")
+	}
+
+	for {
+		g, ok := condition.(Grouping)
+		if !ok {
+			break
+		}
+		condition = g.Expr
+	}
+	if literal, ok := condition.(Literal); ok {
+		truthy := literal.Value != nil && literal.Value != false
+		l.warn(RuleConstantCondition, literal.Span, fmt.Sprintf("Condition is always %v.", truthy))
+	}
+}
+
+// Helper
+
+func firstLine(line, fallback int) int {
+	if line != 0 {
+		return line
+	}
+	return fallback
+}
+
+func (l *Linter) block(stmts []Stmt) {
+	l.beginScope()
+	for _, s := range stmts {
+		l.stmt(s)
+	}
+	l.endScope()
+}
+
+func (l *Linter) declare(name Token) {
+	if _, ok := l.scope.vars[name.Lexeme]; !ok {
+		for s := l.scope.enclosing; s != nil; s = s.enclosing {
+			if outer, ok := s.vars[name.Lexeme]; ok {
+				l.warn(RuleShadowing, TokenSpan("", name), fmt.Sprintf("'%s' shadows the variable declared on line %d.", name.Lexeme, outer.name.Line))
+				break
+			}
+		}
+	}
+	v := &lintVar{name: name}
+	l.scope.vars[name.Lexeme] = v
+	l.scope.order = append(l.scope.order, v)
+}
+
+func (l *Linter) lookup(name string) *lintVar {
+	for s := l.scope; s != nil; s = s.enclosing {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (l *Linter) beginScope() {
+	l.scope = &lintScope{enclosing: l.scope, vars: make(map[string]*lintVar)}
+}
+
+// endScope reports the unused globals, names starting with '_' are meant
+// to be unused. The unused variables of blocks are left to the
+// unused-local warning of the Resolver.
+func (l *Linter) endScope() {
+	for _, v := range l.scope.order {
+		if l.scope.enclosing == nil && !v.used && !strings.HasPrefix(v.name.Lexeme, "_") {
+			l.warn(RuleUnusedVariable, TokenSpan("", v.name), "'"+v.name.Lexeme+"' is declared but never used.")
+		}
+	}
+	l.scope = l.scope.enclosing
+}
+
+// warn records a warning at span, or on the line of the statement being
+// linted when the node has no position
+func (l *Linter) warn(rule string, span Span, message string) {
+	if l.Config.enabled(rule) {
+		l.Warnings = append(l.Warnings, &LintWarning{rule, firstLine(span.Start.Line, l.line), message, span})
+	}
+}
+
+// ignoredLines maps the lines with a 'rof:ignore' comment to the rules
+// ignored there, an empty set ignores every rule. A comment after code
+// applies to its line, a comment on its own line to the next line of code.
+func ignoredLines(tokens []Token) map[int]map[string]bool {
+	return directiveLines(tokens, func(text string) ([]string, bool) {
+		fields := strings.Fields(text)
+		if len(fields) == 0 || fields[0] != "rof:ignore" {
+			return nil, false
+		}
+		var rules []string
+		for _, rule := range fields[1:] {
+			rules = append(rules, strings.Split(rule, ",")...)
+		}
+		return rules, true
+	})
+}
+
+// directiveLines maps the lines of the comments parse recognizes to the
+// names they list. A comment after code applies to its line, a comment
+// on its own line to the next line of code.
+func directiveLines(tokens []Token, parse func(text string) ([]string, bool)) map[int]map[string]bool {
+	lines := map[int]map[string]bool{}
+	for n, t := range tokens {
+		ownLine := n == 0
+		for _, tr := range t.Leading {
+			if tr.Kind == Newline {
+				ownLine = true
+			}
+			if tr.Kind != LineComment && tr.Kind != BlockComment {
+				continue
+			}
+			text := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(tr.Text, "//"), "/*"), "*/")
+			names, ok := parse(text)
+			if !ok {
+				continue
+			}
+			line := t.Line
+			if !ownLine {
+				line = tokens[n-1].Line
+			}
+			if lines[line] == nil {
+				lines[line] = map[string]bool{}
+			}
+			for _, name := range names {
+				if name != "" {
+					lines[line][name] = true
+				}
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,141 @@
+package rof
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		// unused-variable
+		{"var a = 1;", []string{"line #1: 'a' is declared but never used. [unused-variable]"}},
+		{"var a = 1; print a;", nil},
+		{"var _a = 1;", nil},
+		{"record P(x);", []string{"line #1: 'P' is declared but never used. [unused-variable]"}},
+		{"record P(x); print 1 is P;", nil},
+		{"{ var a = 1; }", nil},
+
+		// shadowing
+		{"var a = 1; { var a = 2; print a; } print a;", []string{"line #1: 'a' shadows the variable declared on line 1. [shadowing]"}},
+		{"var a = 1;\n{ var b = 2; print b; }\nprint a;", nil},
+		{"var a = 1;\nvar a = 2; print a;", []string{"line #1: 'a' is declared but never used. [unused-variable]"}},
+
+		// assign-in-condition
+		{"var a; if (a = 1) print a;", []string{"line #1: Assignment used as a condition, did you mean '=='? [assign-in-condition]"}},
+		{"var a; while (a == 1) print a;", nil},
+
+		// self-comparison
+		{"var a = 1; print a == a;", []string{"line #1: Comparison of 'a' with itself. [self-comparison]"}},
+		{"var a = 1; print a < a + 1;", nil},
+		{"print clock() == clock();", nil},
+
+		// constant-condition
+		{"if (true) print 1;", []string{"line #1: Condition is always true. [constant-condition]"}},
+		{"while ((nil)) print 1;", []string{"line #1: Condition is always false. [constant-condition]"}},
+		{`print if ("a") 1 else 2;`, []string{"line #1: Condition is always true. [constant-condition]"}},
+
+		// empty-block
+		{"{}", []string{"line #1: Empty block. [empty-block]"}},
+		{"var a = 1;\nif (a == 2) {\n}", []string{"line #2: Empty block. [empty-block]"}},
+
+		// Sorted by line
+		{"var b = 1;\nif (false) print 2;", []string{
+			"line #1: 'b' is declared but never used. [unused-variable]",
+			"line #2: Condition is always false. [constant-condition]",
+		}},
+	}
+	for _, tt := range tests {
+		warnings, err := Lint(tt.source, LintConfig{})
+		if err != nil {
+			t.Errorf("%q: %v", tt.source, err)
+			continue
+		}
+		var got []string
+		for _, w := range warnings {
+			got = append(got, w.Error())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%q: warnings %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestLintIgnore(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"{} // rof:ignore", nil},
+		{"{} // rof:ignore empty-block", nil},
+		{"{} /* rof:ignore shadowing,empty-block */", nil},
+		{"{} // rof:ignore shadowing", []string{"empty-block"}},
+		{"// rof:ignore\n{}", nil},
+		{"// rof:ignore\n\n{}", nil},
+		{"{}\n// rof:ignore", []string{"empty-block"}},
+		{"var a = 1; // rof:ignore unused-variable\nif (true) {}", []string{"constant-condition", "empty-block"}},
+		{"if (true) {} // rof:ignore empty-block", []string{"constant-condition"}},
+		{"{} // rof: ignore", []string{"empty-block"}},
+	}
+	for _, tt := range tests {
+		warnings, err := Lint(tt.source, LintConfig{})
+		if err != nil {
+			t.Errorf("%q: %v", tt.source, err)
+			continue
+		}
+		var got []string
+		for _, w := range warnings {
+			got = append(got, w.Rule)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%q: rules %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	source := "var a = 1; {}"
+	config := LintConfig{Rules: map[string]bool{RuleEmptyBlock: false, RuleUnusedVariable: true}}
+	warnings, err := Lint(source, config)
+	if err != nil || len(warnings) != 1 || warnings[0].Rule != RuleUnusedVariable {
+		t.Errorf("warnings %v, %v, want only %s", warnings, err, RuleUnusedVariable)
+	}
+
+	if _, err := Lint("print 1", LintConfig{}); err == nil {
+		t.Error("linting a source that does not parse gives no error")
+	}
+
+	dir, err := ioutil.TempDir("", "lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	files := []struct {
+		content string
+		err     string
+	}{
+		{`{"rules": {"shadowing": false}}`, ""},
+		{`{"rules": {"shadow": false}}`, `unknown rule "shadow"`},
+		{`{"rules": `, "unexpected end of JSON input"},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, "lint.json")
+		if err := ioutil.WriteFile(path, []byte(f.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := LoadLintConfig(path)
+		switch {
+		case f.err == "" && err != nil:
+			t.Errorf("%s: %v", f.content, err)
+		case f.err == "" && config.enabled(RuleShadowing):
+			t.Errorf("%s: shadowing is enabled", f.content)
+		case f.err != "" && (err == nil || !strings.Contains(err.Error(), f.err)):
+			t.Errorf("%s: error %v, want %q", f.content, err, f.err)
+		}
+	}
+}
@@ -5,44 +5,150 @@ import (
 )
 
 type Parser struct {
+	// File names the source in the spans of the nodes
+	File       string
 	Tokens     []Token
 	Statements []Stmt
 	Current    int
 	HadError   bool
+	// Errors holds every syntax error, they are also printed unless
+	// Quiet is set
+	Errors []error
+	Quiet  bool
+	// Warnings holds the warnings, they are never printed
+	Warnings []Diagnostic
+	// depth is the number of blocks being parsed
+	depth int
 }
 
-func (p *Parser) Parse() []Stmt {
+// Parse - Parse the declarations and return every syntax error. A
+// statement with an error is dropped and parsing goes on with the next
+// one, in blocks too, so the statements can be used by tools even when
+// there are errors.
+func (p *Parser) Parse() ([]Stmt, []error) {
+	for !p.isAtEnd() {
+		if stmt := p.safeDeclaration(); stmt != nil {
+			p.Statements = append(p.Statements, stmt)
+		}
+	}
+
+	return p.Statements, p.Errors
+}
+
+// safeDeclaration parses a declaration, nil when it has an error
+func (p *Parser) safeDeclaration() (stmt Stmt) {
+	p.recovering(func() {
+		if p.isTest() && p.depth == 0 {
+			stmt = p.testDeclaration()
+			return
+		}
+		stmt = p.declaration()
+	})
+	return stmt
+}
+
+// recovering runs parse, a syntax error is recorded and parsing resumes
+// at the start of the next statement
+func (p *Parser) recovering(parse func()) {
+	start, current := p.peek(), p.Current
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Println("Parse Error:", r.(error))
+			err, ok := r.(*ParseError)
+			if !ok {
+				panic(r)
+			}
+			// A misspelled keyword parses as a name, the error comes
+			// after it or at it.
+			for _, t := range []Token{start, err.Token} {
+				if t.TokenType != IDENTIFIER || len(err.Suggestions) > 0 {
+					continue
+				}
+				for _, k := range closest(t.Lexeme, Keywords(), 1) {
+					err.Suggestions = append(err.Suggestions, Suggestion{"did you mean the keyword '" + k + "'?", TokenSpan(p.File, t), k})
+				}
+			}
+			if !p.Quiet {
+				fmt.Println("Parse Error:", err)
+			}
+			p.Errors = append(p.Errors, err)
 			p.HadError = true
-			p.synchronize()
+			p.synchronize(current)
 		}
 	}()
 
-	for !p.isAtEnd() {
-		p.Statements = append(p.Statements, p.declaration())
-	}
-
-	return p.Statements
+	parse()
 }
 
 func (p *Parser) declaration() Stmt {
+	if p.isTest() {
+		panic(&ParseError{Token: p.peek(), Message: "Tests must be declared at the top level.", Code: CodeNestedTest})
+	}
 	if p.match(VAR) {
 		return p.varDeclaration()
 	}
+	if p.match(RECORD) {
+		return p.recordDeclaration()
+	}
 
 	return p.statement()
 }
 
+func (p *Parser) isTest() bool {
+	return p.check(IDENTIFIER) && p.peek().Lexeme == "test" && p.Tokens[p.Current+1].TokenType == STRING
+}
+
+func (p *Parser) testDeclaration() Stmt {
+	keyword := p.advance()
+	name := p.advance()
+	p.consume(LEFT_BRACE, "Expect '{' after test name.")
+	body := p.block()
+	return Test{keyword, name, body, p.span(keyword)}
+}
+
+func (p *Parser) recordDeclaration() Stmt {
+	keyword := p.previous()
+	name := p.consume(IDENTIFIER, "Expect record name.")
+	paren := p.consume(LEFT_PAREN, "Expect '(' after record name.")
+	fields := []Token{}
+	seen := map[string]Token{}
+	if !p.check(RIGHT_PAREN) {
+		for {
+			field := p.consume(IDENTIFIER, "Expect field name.")
+			if first, ok := seen[field.Lexeme]; ok {
+				panic(&ParseError{
+					Token:     field,
+					Message:   "Duplicate field '" + field.Lexeme + "'.",
+					Code:      CodeDuplicateField,
+					Label:     "declared again",
+					Secondary: []Label{{TokenSpan(p.File, first), "first declared here"}},
+				})
+			}
+			seen[field.Lexeme] = field
+			fields = append(fields, field)
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	p.closing(RIGHT_PAREN, paren, "Expect ')' after record fields.")
+	p.consume(SEMICOLON, "Expect ';' after record declaration.")
+	return Record{name, fields, p.span(keyword)}
+}
+
 func (p *Parser) varDeclaration() Var {
+	keyword := p.previous()
 	tokenName := p.consume(IDENTIFIER, "Expect variable name.")
+	var typeName *Token
+	if p.match(COLON) {
+		t := p.consume(IDENTIFIER, "Expect type name after ':'.")
+		typeName = &t
+	}
 	var initializer Expr
 	if p.match(EQUAL) {
 		initializer = p.expression()
 	}
 	p.consume(SEMICOLON, "Expect ';' after variable declaration.")
-	return Var{Name: tokenName, Initializer: initializer}
+	return Var{Name: tokenName, Type: typeName, Initializer: initializer, Span: p.span(keyword)}
 }
 
 func (p *Parser) expression() Expr {
@@ -50,40 +156,51 @@ func (p *Parser) expression() Expr {
 }
 
 func (p *Parser) assignment() Expr {
+	start := p.peek()
 	expr := p.or()
 	if p.match(EQUAL) {
 		equals := p.previous()
 		value := p.assignment()
 		exprVar, ok := expr.(Variable)
 		if ok {
-			return Assign{exprVar.Name, value}
+			return Assign{exprVar.Name, value, p.span(start)}
+		}
+		if _, ok := expr.(Get); ok {
+			panic(&ParseError{
+				Token:   equals,
+				Message: "Record fields are immutable.",
+				Code:    CodeImmutableField,
+				Help:    []string{"use 'with' to make a copy"},
+			})
 		}
 
-		panic(&ParseError{equals, "Invalid assignment target."})
+		panic(&ParseError{Token: equals, Message: "Invalid assignment target.", Code: CodeInvalidAssignment})
 	}
 
 	return expr
 }
 
 func (p *Parser) or() Expr {
+	start := p.peek()
 	expr := p.and()
 
 	for p.match(OR) {
 		operator := p.previous()
 		right := p.and()
-		return Logical{expr, operator, right}
+		return Logical{expr, operator, right, p.span(start)}
 	}
 
 	return expr
 }
 
 func (p *Parser) and() Expr {
+	start := p.peek()
 	expr := p.equality()
 
 	for p.match(AND) {
 		operator := p.previous()
 		right := p.equality()
-		return Logical{expr, operator, right}
+		return Logical{expr, operator, right, p.span(start)}
 	}
 
 	return expr
@@ -103,14 +220,29 @@ func (p *Parser) statement() Stmt {
 		return p.whileStatement()
 	}
 	if p.match(LEFT_BRACE) {
-		return Block{p.block()}
+		brace := p.previous()
+		statements := p.block()
+		return Block{statements, p.span(brace)}
+	}
+	if p.match(SEMICOLON) {
+		semicolon := p.previous()
+		span := TokenSpan(p.File, semicolon)
+		p.Warnings = append(p.Warnings, Diagnostic{
+			Severity:    SeverityWarning,
+			Code:        WarnEmptyStatement,
+			Message:     "Empty statement.",
+			Span:        span,
+			Suggestions: []Suggestion{{"remove the ';'", span, ""}},
+		})
+		return Empty{semicolon, span}
 	}
 
 	return p.expressionStatement()
 }
 
 func (p *Parser) forStatement() Stmt {
-	p.consume(LEFT_PAREN, "Expect '(' after 'for'.")
+	keyword := p.previous()
+	paren := p.consume(LEFT_PAREN, "Expect '(' after 'for'.")
 
 	var initializer Stmt
 	switch {
@@ -132,39 +264,43 @@ func (p *Parser) forStatement() Stmt {
 	if !p.check(RIGHT_PAREN) {
 		increment = p.expression()
 	}
-	p.consume(RIGHT_PAREN, "Expect ')' after for clauses.")
+	p.closing(RIGHT_PAREN, paren, "Expect ')' after for clauses.")
 	body := p.statement()
 
+	// The nodes added by the desugaring cover the whole loop.
+	span := p.span(keyword)
 	if increment != nil {
-		body = Block{[]Stmt{body, Expression{increment}}}
+		body = Block{[]Stmt{body, Expression{increment, ExprSpan(increment)}}, span}
 	}
 
 	if condition == nil {
-		condition = Literal{true}
+		condition = Literal{true, span}
 	}
 
-	body = While{condition, body}
+	body = While{keyword, condition, body, span}
 
 	if initializer != nil {
-		body = Block{[]Stmt{initializer, body}}
+		body = Block{[]Stmt{initializer, body}, span}
 	}
 
 	return body
 }
 
 func (p *Parser) whileStatement() Stmt {
-	p.consume(LEFT_PAREN, "Expect '(' before while condition.")
+	keyword := p.previous()
+	paren := p.consume(LEFT_PAREN, "Expect '(' before while condition.")
 	condition := p.expression()
-	p.consume(RIGHT_PAREN, "Expect ')' after while condition")
+	p.closing(RIGHT_PAREN, paren, "Expect ')' after while condition")
 	body := p.statement()
 
-	return While{condition, body}
+	return While{keyword, condition, body, p.span(keyword)}
 }
 
 func (p *Parser) ifStatement() Stmt {
-	p.consume(LEFT_PAREN, "Expect '(' before if condition.")
+	keyword := p.previous()
+	paren := p.consume(LEFT_PAREN, "Expect '(' before if condition.")
 	condition := p.expression()
-	p.consume(RIGHT_PAREN, "Expect ')' after if condition")
+	p.closing(RIGHT_PAREN, paren, "Expect ')' after if condition")
 
 	then := p.statement()
 	var elseBranch Stmt
@@ -172,38 +308,47 @@ func (p *Parser) ifStatement() Stmt {
 		elseBranch = p.statement()
 	}
 
-	return If{condition, then, elseBranch}
+	return If{keyword, condition, then, elseBranch, p.span(keyword)}
 }
 
 func (p *Parser) block() []Stmt {
+	p.depth++
+	defer func() { p.depth-- }()
+
+	brace := p.previous()
 	s := []Stmt{}
 	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
-		s = append(s, p.declaration())
+		if stmt := p.safeDeclaration(); stmt != nil {
+			s = append(s, stmt)
+		}
 	}
 
-	p.consume(RIGHT_BRACE, "Expect '}' after block.")
+	p.closing(RIGHT_BRACE, brace, "Expect '}' after block.")
 	return s
 }
 
 func (p *Parser) printStatement() Stmt {
+	keyword := p.previous()
 	value := p.expression()
 	p.consume(SEMICOLON, "Expect ; after value.")
-	return Print{value}
+	return Print{keyword, value, p.span(keyword)}
 }
 
 func (p *Parser) expressionStatement() Stmt {
+	start := p.peek()
 	value := p.expression()
 	p.consume(SEMICOLON, "Expect ; after expression.")
-	return Expression{value}
+	return Expression{value, p.span(start)}
 }
 
 func (p *Parser) equality() Expr {
 	//fmt.Println("[DEBUG] Equality ->", p.peek())
+	start := p.peek()
 	expr := p.comparison()
-	for p.match(BANG, BANG_EQUAL) {
+	for p.match(BANG_EQUAL, EQUAL_EQUAL) {
 		operator := p.previous()
 		right := p.comparison()
-		expr = Binary{Left: expr, Operator: operator, Right: right}
+		expr = Binary{Left: expr, Operator: operator, Right: right, Span: p.span(start)}
 	}
 
 	return expr
@@ -211,13 +356,25 @@ func (p *Parser) equality() Expr {
 
 func (p *Parser) comparison() Expr {
 	//fmt.Println("[DEBUG] Comparison ->", p.peek())
+	start := p.peek()
 	expr := p.addition()
 
 	for p.match(GREATER, GREATER_EQUAL, LESS, LESS_EQUAL) {
 		operator := p.previous()
 		right := p.addition()
 		//fmt.Println("[DEBUG] IS Comparison")
-		expr = Binary{Left: expr, Operator: operator, Right: right}
+		expr = Binary{Left: expr, Operator: operator, Right: right, Span: p.span(start)}
+	}
+
+	if p.match(IS) {
+		keyword := p.previous()
+		var typeName Token
+		if p.match(NIL) {
+			typeName = p.previous()
+		} else {
+			typeName = p.consume(IDENTIFIER, "Expect type name after 'is'.")
+		}
+		expr = Is{expr, keyword, typeName, p.span(start)}
 	}
 
 	return expr
@@ -225,13 +382,14 @@ func (p *Parser) comparison() Expr {
 
 func (p *Parser) addition() Expr {
 	//fmt.Println("[DEBUG] Addition ->", p.peek())
+	start := p.peek()
 	expr := p.multiplication()
 
 	for p.match(MINUS, PLUS) {
 		operator := p.previous()
 		right := p.multiplication()
 		//fmt.Println("[DEBUG] IS Addition")
-		expr = Binary{Left: expr, Operator: operator, Right: right}
+		expr = Binary{Left: expr, Operator: operator, Right: right, Span: p.span(start)}
 	}
 
 	return expr
@@ -239,13 +397,14 @@ func (p *Parser) addition() Expr {
 
 func (p *Parser) multiplication() Expr {
 	//fmt.Println("[DEBUG] Multiplication ->", p.peek())
+	start := p.peek()
 	expr := p.unary()
 
 	for p.match(SLASH, STAR) {
 		operator := p.previous()
 		right := p.unary()
 		//fmt.Println("[DEBUG] IS Multiplication")
-		expr = Binary{Left: expr, Operator: operator, Right: right}
+		expr = Binary{Left: expr, Operator: operator, Right: right, Span: p.span(start)}
 	}
 
 	return expr
@@ -257,18 +416,24 @@ func (p *Parser) unary() Expr {
 		operator := p.previous()
 		right := p.unary()
 		//fmt.Println("[DEBUG] IS Unary")
-		return Unary{Operator: operator, Right: right}
+		return Unary{Operator: operator, Right: right, Span: p.span(operator)}
 	}
 
 	return p.call()
 }
 
 func (p *Parser) call() Expr {
+	start := p.peek()
 	expr := p.primary()
 
 	for {
 		if p.match(LEFT_PAREN) {
-			expr = p.finishCall(expr)
+			expr = p.finishCall(start, expr)
+		} else if p.match(DOT) {
+			name := p.consume(IDENTIFIER, "Expect field name after '.'.")
+			expr = Get{expr, name, p.span(start)}
+		} else if p.match(WITH) {
+			expr = p.finishWith(start, expr)
 		} else {
 			break
 		}
@@ -277,54 +442,188 @@ func (p *Parser) call() Expr {
 	return expr
 }
 
-func (p *Parser) finishCall(expr Expr) Expr {
+func (p *Parser) finishCall(start Token, expr Expr) Expr {
+	open := p.previous()
 	var args []Expr
 
 	if !p.check(RIGHT_PAREN) {
 		args = append(args, p.expression())
 		for p.match(COMMA) {
 			if len(args) > 255 {
-				panic(ParseError{p.peek(), "Cannot have more than 255 arguments."})
+				panic(&ParseError{Token: p.peek(), Message: "Cannot have more than 255 arguments.", Code: CodeTooManyArguments})
 			}
 			args = append(args, p.expression())
 		}
 	}
-	paren := p.consume(RIGHT_PAREN, "Expect ')' after arguments.")
+	paren := p.closing(RIGHT_PAREN, open, "Expect ')' after arguments.")
 
-	return Call{expr, paren, args}
+	return Call{expr, paren, args, p.span(start)}
 
 }
 
+func (p *Parser) finishWith(start Token, expr Expr) Expr {
+	keyword := p.previous()
+	brace := p.consume(LEFT_BRACE, "Expect '{' after 'with'.")
+	var names []Token
+	var values []Expr
+	if !p.check(RIGHT_BRACE) {
+		for {
+			names = append(names, p.consume(IDENTIFIER, "Expect field name."))
+			p.consume(COLON, "Expect ':' after field name.")
+			values = append(values, p.expression())
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	p.closing(RIGHT_BRACE, brace, "Expect '}' after fields.")
+
+	return With{expr, keyword, names, values, p.span(start)}
+}
+
 func (p *Parser) primary() Expr {
+	start := p.peek()
 	if p.match(FALSE) {
-		return Literal{Value: false}
+		return Literal{Value: false, Span: p.span(start)}
 	}
 	if p.match(TRUE) {
-		return Literal{Value: true}
+		return Literal{Value: true, Span: p.span(start)}
 	}
 	if p.match(NIL) {
-		return Literal{Value: nil}
+		return Literal{Value: nil, Span: p.span(start)}
 	}
 
 	if p.match(NUMBER, STRING) {
-		return Literal{p.previous().Literal}
+		return Literal{p.previous().Literal, p.span(start)}
 	}
 
 	if p.match(IDENTIFIER) {
-		return Variable{p.previous()}
+		return Variable{p.previous(), p.span(start)}
 	}
 
 	if p.match(LEFT_PAREN) {
 		expr := p.expression()
-		p.consume(RIGHT_PAREN, "Expect ')' after expression.")
-		return Grouping{expr}
+		p.closing(RIGHT_PAREN, start, "Expect ')' after expression.")
+		return Grouping{expr, p.span(start)}
 	}
 
-	panic(&ParseError{p.peek(), "Expect expression"})
+	if p.match(IF) {
+		return p.conditional()
+	}
+	if p.match(WHILE) {
+		return p.loop()
+	}
+	if p.match(LEFT_BRACE) {
+		return p.compound()
+	}
+
+	panic(&ParseError{Token: p.peek(), Message: "Expect expression", Code: CodeExpectedExpression})
+}
+
+func (p *Parser) conditional() Expr {
+	keyword := p.previous()
+	paren := p.consume(LEFT_PAREN, "Expect '(' before if condition.")
+	condition := p.expression()
+	p.closing(RIGHT_PAREN, paren, "Expect ')' after if condition")
+
+	then := p.expression()
+	p.consume(ELSE, "Expect 'else' branch in if expression.")
+	elseBranch := p.expression()
+
+	return Conditional{keyword, condition, then, elseBranch, p.span(keyword)}
+}
+
+func (p *Parser) loop() Expr {
+	keyword := p.previous()
+	paren := p.consume(LEFT_PAREN, "Expect '(' before while condition.")
+	condition := p.expression()
+	p.closing(RIGHT_PAREN, paren, "Expect ')' after while condition")
+
+	body := p.expression()
+	return Loop{condition, body, p.span(keyword)}
+}
+
+// compound parses a block in expression position. Lines starting with
+// a statement keyword are statements, the first expression not followed
+// by ';' is the value of the block and must be the last one. An if, a
+// while or a block is parsed as an expression when it can be one.
+func (p *Parser) compound() Expr {
+	p.depth++
+	defer func() { p.depth-- }()
+
+	brace := p.previous()
+	s := []Stmt{}
+	var value Expr
+	for value == nil && !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		p.recovering(func() {
+			start := p.peek()
+			switch start.TokenType {
+			case IF, WHILE, LEFT_BRACE:
+				// The value of the block, or an expression statement,
+				// when it parses as one, else a statement.
+				if expr := p.tryExpression(RIGHT_BRACE, SEMICOLON); expr != nil {
+					if !p.match(SEMICOLON) {
+						value = expr
+						return
+					}
+					s = append(s, Expression{expr, p.span(start)})
+					return
+				}
+				s = append(s, p.declaration())
+				return
+			case VAR, RECORD, FOR, PRINT, SEMICOLON:
+				s = append(s, p.declaration())
+				return
+			}
+
+			expr := p.expression()
+			if !p.match(SEMICOLON) {
+				value = expr
+				return
+			}
+			s = append(s, Expression{expr, p.span(start)})
+		})
+	}
+
+	p.closing(RIGHT_BRACE, brace, "Expect '}' after block.")
+	return Compound{s, value, p.span(brace)}
+}
+
+// tryExpression parses an expression followed by one of the end
+// tokens. When it has a syntax error or another token follows, it
+// returns nil and the parser is back where it started.
+func (p *Parser) tryExpression(end ...TokenType) (expr Expr) {
+	current, errors, warnings, quiet := p.Current, len(p.Errors), len(p.Warnings), p.Quiet
+	hadError := p.HadError
+	p.Quiet = true
+	defer func() {
+		p.Quiet = quiet
+		r := recover()
+		if r != nil {
+			if _, ok := r.(*ParseError); !ok {
+				panic(r)
+			}
+		}
+		ended := false
+		for _, t := range end {
+			ended = ended || p.check(t)
+		}
+		if r != nil || len(p.Errors) > errors || !ended {
+			p.Current, p.Errors, p.Warnings, p.HadError = current, p.Errors[:errors], p.Warnings[:warnings], hadError
+			expr = nil
+		}
+	}()
+	return p.expression()
 }
 
 // Helper
 
+// span returns the source range from the start of a token to the end of
+// the last token consumed
+func (p *Parser) span(start Token) Span {
+	return Span{TokenSpan(p.File, start).Start, TokenSpan(p.File, p.previous()).End}
+}
+
 func (p *Parser) match(types ...TokenType) bool {
 	for _, t := range types {
 		if p.check(t) {
@@ -367,27 +666,58 @@ func (p *Parser) consume(t TokenType, text string) Token {
 		return p.advance()
 	}
 
-	panic(&ParseError{p.peek(), text})
+	err := &ParseError{Token: p.peek(), Message: text, Code: CodeExpectedToken}
+	if t == SEMICOLON && p.Current > 0 {
+		err.Secondary = []Label{{TokenSpan(p.File, p.previous()), "expected ';' after this"}}
+	}
+	panic(err)
 }
 
-func (p *Parser) synchronize() {
-	p.advance()
+// closing consumes the token closing open, the error points at both
+func (p *Parser) closing(t TokenType, open Token, text string) Token {
+	if p.check(t) {
+		return p.advance()
+	}
+
+	panic(&ParseError{
+		Token:     p.peek(),
+		Message:   text,
+		Code:      CodeExpectedToken,
+		Secondary: []Label{{TokenSpan(p.File, open), "'" + open.Lexeme + "' opened here"}},
+	})
+}
+
+// synchronize skips to the start of the next statement after a syntax
+// error in the statement starting at start. It stops before an opening
+// brace, which starts a block, and in a block before the closing brace,
+// so the block still ends there. At the top level a closing brace ends
+// the statement. At least one token is skipped when the statement
+// failed at its first one.
+func (p *Parser) synchronize(start int) {
+	if p.depth > 0 && p.check(RIGHT_BRACE) {
+		return
+	}
+	if p.Current == start {
+		p.advance()
+	}
 
 	for !p.isAtEnd() {
-		if p.previous().TokenType == SEMICOLON {
+		switch p.previous().TokenType {
+		case SEMICOLON:
 			return
+		case RIGHT_BRACE:
+			if p.depth == 0 {
+				return
+			}
 		}
 
 		switch p.peek().TokenType {
-		case CLASS:
-		case FUN:
-		case VAR:
-		case FOR:
-		case IF:
-		case WHILE:
-		case PRINT:
-		case RETURN:
+		case CLASS, FUN, VAR, RECORD, FOR, IF, WHILE, PRINT, RETURN, LEFT_BRACE:
 			return
+		case RIGHT_BRACE:
+			if p.depth > 0 {
+				return
+			}
 		}
 
 		p.advance()
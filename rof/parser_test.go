@@ -0,0 +1,123 @@
+package rof
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBlockValue(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"print { 1 };", "(print (compound 1))"},
+		{"print { if (c) 1 else 2 };", "(print (compound (if c 1 2)))"},
+		{"print { { 1 } };", "(print (compound (compound 1)))"},
+		{"print { while (c) 1 };", "(print (compound (while c 1)))"},
+		{"print { if (c) 1 else 2; 3 };", "(print (compound (expr (if c 1 2)) 3))"},
+		{"print { if (c) { print 1; } 4 };", "(print (compound (if c (block (print 1))) 4))"},
+		{"print { if (c) print 1; 5 };", "(print (compound (if c (print 1)) 5))"},
+		{"print { { var x = 6; } 7 };", "(print (compound (block (var x 6)) 7))"},
+	}
+	for _, tt := range tests {
+		stmts, errs := parse(t, tt.source)
+		if len(errs) > 0 {
+			t.Errorf("%q: %v", tt.source, errs)
+			continue
+		}
+		if got := strings.TrimSuffix((&ASTPrinter{}).Print(stmts), "\n"); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	source := "var a = 1 + 2;\nprint\n  a;\n{ a = 3; }"
+	stmts, errs := parse(t, source)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	tests := []struct {
+		span Span
+		text string
+		from string
+		to   string
+	}{
+		{StmtSpan(stmts[0]), "var a = 1 + 2;", "test.rof:1:1", "1:15"},
+		{ExprSpan(stmts[0].(Var).Initializer), "1 + 2", "test.rof:1:9", "1:14"},
+		{StmtSpan(stmts[1]), "print\n  a;", "test.rof:2:1", "3:5"},
+		{StmtSpan(stmts[2]), "{ a = 3; }", "test.rof:4:1", "4:11"},
+		{StmtSpan(stmts[2].(Block).Statements[0]), "a = 3;", "test.rof:4:3", "4:9"},
+	}
+	for _, tt := range tests {
+		if got := source[tt.span.Start.Offset:tt.span.End.Offset]; got != tt.text {
+			t.Errorf("span %v covers %q, want %q", tt.span, got, tt.text)
+		}
+		if got := tt.span.String(); got != tt.from+"-"+tt.to {
+			t.Errorf("span of %q is %s, want %s-%s", tt.text, got, tt.from, tt.to)
+		}
+	}
+}
+
+func TestParseRecovery(t *testing.T) {
+	tests := []struct {
+		source string
+		// the statements kept, as S-expressions
+		want   string
+		errors []string
+	}{
+		{
+			source: "var a = ; print 1;",
+			want:   "(print 1)",
+			errors: []string{"line #1 at ';': Expect expression"},
+		},
+		{
+			source: "print 1 print 2; print 3;",
+			want:   "(print 2)\n(print 3)",
+			errors: []string{"line #1 at 'print': Expect ; after value."},
+		},
+		{
+			source: "{ var a = ; print 2; } print 3;",
+			want:   "(block (print 2))\n(print 3)",
+			errors: []string{"line #1 at ';': Expect expression"},
+		},
+		{
+			source: "var x = 1 } print 5;",
+			want:   "(print 5)",
+			errors: []string{"line #1 at '}': Expect ';' after variable declaration."},
+		},
+		{
+			source: "print (1; print 6;",
+			want:   "(print 6)",
+			errors: []string{"line #1 at ';': Expect ')' after expression."},
+		},
+		{
+			source: "if (true) { print ; } print 7;",
+			want:   "(if true (block))\n(print 7)",
+			errors: []string{"line #1 at ';': Expect expression"},
+		},
+		{
+			source: "var = 1; var = 2; print 8;",
+			want:   "(print 8)",
+			errors: []string{"line #1 at '=': Expect variable name.", "line #1 at '=': Expect variable name."},
+		},
+		{
+			source: "print 1;\nprint 2;",
+			want:   "(print 1)\n(print 2)",
+		},
+	}
+	for _, tt := range tests {
+		stmts, errs := parse(t, tt.source)
+		got := strings.TrimSuffix((&ASTPrinter{}).Print(stmts), "\n")
+		if got != tt.want {
+			t.Errorf("%q: statements\n%s\nwant\n%s", tt.source, got, tt.want)
+		}
+		var messages []string
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		if strings.Join(messages, "\n") != strings.Join(tt.errors, "\n") {
+			t.Errorf("%q: errors %q, want %q", tt.source, messages, tt.errors)
+		}
+	}
+}
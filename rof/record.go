@@ -0,0 +1,176 @@
+package rof
+
+import (
+	"hash/fnv"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// RecordType - Constructor created by a record declaration
+type RecordType struct {
+	RecordName string
+	Fields     []string
+}
+
+// RecordInstance - Immutable value built by calling a RecordType
+type RecordInstance struct {
+	Type   *RecordType
+	Values []interface{}
+}
+
+// Call builds a new instance with the arguments as field values
+func (r *RecordType) Call(i Interpreter, arguments []interface{}) interface{} {
+	values := make([]interface{}, len(arguments))
+	copy(values, arguments)
+	return &RecordInstance{r, values}
+}
+
+// Arity returns the number of fields of the record
+func (r *RecordType) Arity() int {
+	return len(r.Fields)
+}
+
+// Name returns the name of the record
+func (r *RecordType) Name() string {
+	return r.RecordName
+}
+
+func (r *RecordType) String() string {
+	return "<record " + r.RecordName + ">"
+}
+
+func (r *RecordType) field(name string) int {
+	for n, f := range r.Fields {
+		if f == name {
+			return n
+		}
+	}
+	return -1
+}
+
+func (r *RecordType) undefinedField(name Token) *RuntimeError {
+	return &RuntimeError{
+		Token:   name,
+		Message: "Undefined field '" + name.Lexeme + "' on " + r.RecordName + ".",
+		Code:    CodeUndefinedField,
+		Notes:   []string{r.RecordName + " has the fields " + strings.Join(r.Fields, ", ")},
+	}
+}
+
+// Get returns the value of a field
+func (r *RecordInstance) Get(name Token) interface{} {
+	n := r.Type.field(name.Lexeme)
+	if n == -1 {
+		panic(r.Type.undefinedField(name))
+	}
+	return r.Values[n]
+}
+
+// With returns a copy of the instance with some fields replaced
+func (r *RecordInstance) With(names []Token, values []interface{}) *RecordInstance {
+	copied := make([]interface{}, len(r.Values))
+	copy(copied, r.Values)
+	for k, name := range names {
+		n := r.Type.field(name.Lexeme)
+		if n == -1 {
+			panic(r.Type.undefinedField(name))
+		}
+		copied[n] = values[k]
+	}
+	return &RecordInstance{r.Type, copied}
+}
+
+// Equal compares two instances by type and content
+func (r *RecordInstance) Equal(other *RecordInstance) bool {
+	if r.Type != other.Type {
+		return false
+	}
+	for n := range r.Values {
+		if !valuesEqual(r.Values[n], other.Values[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns a hash consistent with Equal, stable across runs
+func (r *RecordInstance) Hash() uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(r.Type.RecordName))
+	for _, v := range r.Values {
+		var b [8]byte
+		sum := HashValue(v)
+		for n := range b {
+			b[n] = byte(sum >> (8 * n))
+		}
+		h.Write(b[:])
+	}
+	return h.Sum64()
+}
+
+func (r *RecordInstance) String() string {
+	var sb strings.Builder
+	sb.WriteString(r.Type.RecordName + "(")
+	for n, field := range r.Type.Fields {
+		if n > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(field + ": ")
+		if s, ok := r.Values[n].(string); ok {
+			sb.WriteString(strconv.Quote(s))
+		} else {
+			sb.WriteString(Stringify(r.Values[n]))
+		}
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// HashValue returns the hash of any runtime value, equal values
+// have the same hash
+func HashValue(value interface{}) uint64 {
+	h := fnv.New64a()
+	switch t := value.(type) {
+	case nil:
+		h.Write([]byte("nil"))
+	case bool:
+		h.Write([]byte(strconv.FormatBool(t)))
+	case float64:
+		if t == 0 {
+			// 0 and -0 compare equal.
+			t = 0
+		}
+		h.Write([]byte(strconv.FormatUint(math.Float64bits(t), 16)))
+	case string:
+		h.Write([]byte("s" + t))
+	case *RecordInstance:
+		return t.Hash()
+	default:
+		h.Write([]byte(Stringify(t)))
+	}
+	return h.Sum64()
+}
+
+// valuesEqual compares two runtime values, records by value and
+// builtins by name. Values Go cannot compare are never equal.
+func valuesEqual(a, b interface{}) bool {
+	ra, okA := a.(*RecordInstance)
+	rb, okB := b.(*RecordInstance)
+	if okA && okB {
+		return ra.Equal(rb)
+	}
+	if fa, ok := a.(NativeFunction); ok {
+		fb, ok := b.(NativeFunction)
+		return ok && fa.FunctionName == fb.FunctionName
+	}
+	if !isComparable(a) || !isComparable(b) {
+		return false
+	}
+	return a == b
+}
+
+func isComparable(value interface{}) bool {
+	return value == nil || reflect.TypeOf(value).Comparable()
+}
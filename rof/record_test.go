@@ -0,0 +1,100 @@
+package rof
+
+import "testing"
+
+const records = `
+record P(x, y);
+record Q(x, y);
+record Box(value);
+record Empty();
+var p = P(1, "a");
+`
+
+func TestRecordEquality(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`p == P(1, "a")`, "true"},
+		{`p == P(1, "b")`, "false"},
+		{`p == Q(1, "a")`, "false"},
+		{`p != P(2, "a")`, "true"},
+		{`p == p`, "true"},
+		{`p == 1`, "false"},
+		{`p == nil`, "false"},
+		{`Box(P(1, 2)) == Box(P(1, 2))`, "true"},
+		{`Box(P(1, 2)) == Box(P(1, 3))`, "false"},
+		{`Box(clock) == Box(clock)`, "true"},
+		{`Box(clock) == Box(typeof)`, "false"},
+		{`Box(0) == Box(-0)`, "true"},
+		{`Box(nil) == Box(false)`, "false"},
+		{`Empty() == Empty()`, "true"},
+		{`P == P`, "true"},
+		{`P == Q`, "false"},
+		{`clock == clock`, "true"},
+	}
+	for _, tt := range tests {
+		got, err := run(t, records+"print "+tt.expr+";")
+		if err != nil || got != tt.want {
+			t.Errorf("%s = %q, %v, want %q", tt.expr, got, err, tt.want)
+		}
+	}
+}
+
+func TestRecordHash(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{`P(1, "a")`, `P(1, "a")`, true},
+		{`Box(P(1, 2))`, `Box(P(1, 2))`, true},
+		{`Box(0)`, `Box(-0)`, true},
+		{`P(1, "a")`, `P(1, "b")`, false},
+		{`P(1, "a")`, `Q(1, "a")`, false},
+		{`P(1, 2)`, `P(2, 1)`, false},
+		{`Box("1")`, `Box(1)`, false},
+		{`Box(nil)`, `Box(false)`, false},
+	}
+	for _, tt := range tests {
+		got, err := run(t, records+"print hash("+tt.a+") == hash("+tt.b+");")
+		want := "false"
+		if tt.equal {
+			want = "true"
+		}
+		if err != nil || got != want {
+			t.Errorf("hash(%s) == hash(%s) is %q, %v, want %s", tt.a, tt.b, got, err, want)
+		}
+	}
+}
+
+func TestRecordFields(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+		err  string
+	}{
+		{`p`, `P(x: 1, y: "a")`, ""},
+		{`p.x`, "1", ""},
+		{`p.y`, "a", ""},
+		{`p with { x: 2 }`, `P(x: 2, y: "a")`, ""},
+		{`p with { y: nil, x: 3 }`, `P(x: 3, y: nil)`, ""},
+		{`{ var q = p with { x: 2 }; p.x }`, "1", ""},
+		{`(p with { x: 2 }).x`, "2", ""},
+		{`p with { x: 1 } == p`, "true", ""},
+		{`p.z`, "", "Undefined field 'z' on P."},
+		{`p with { z: 1 }`, "", "Undefined field 'z' on P."},
+		{`(1).x`, "", "Only records have fields."},
+		{`1 with { x: 1 }`, "", "Only records can be copied with 'with'."},
+		{`P(1)`, "", "Expected 2 arguments but got 1."},
+	}
+	for _, tt := range tests {
+		got, err := run(t, records+"print "+tt.expr+";")
+		message := ""
+		if err != nil {
+			message = err.(*RuntimeError).Message
+		}
+		if got != tt.want || message != tt.err {
+			t.Errorf("%s = %q, %q, want %q, %q", tt.expr, got, message, tt.want, tt.err)
+		}
+	}
+}
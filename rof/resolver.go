@@ -0,0 +1,235 @@
+package rof
+
+import "strings"
+
+// Resolver - Static pass binding the uses of variables to their
+// declarations, it warns about the local variables never read.
+// Assigning a variable does not count as reading it.
+type Resolver struct {
+	// File names the source in the spans of the warnings
+	File     string
+	Warnings []Diagnostic
+	scope    *resolveScope
+}
+
+type resolveScope struct {
+	enclosing *resolveScope
+	vars      map[string]*resolveVar
+	// order keeps the warnings in declaration order
+	order []*resolveVar
+}
+
+type resolveVar struct {
+	name Token
+	read bool
+}
+
+// Resolve walks the statements and returns the warnings
+func (r *Resolver) Resolve(stmts []Stmt) []Diagnostic {
+	for _, s := range stmts {
+		r.stmt(s)
+	}
+	return r.Warnings
+}
+
+func (r *Resolver) stmt(stmt Stmt) {
+	if stmt != nil {
+		stmt.Accept(r)
+	}
+}
+
+func (r *Resolver) expr(expr Expr) {
+	if expr != nil {
+		expr.Accept(r)
+	}
+}
+
+func (r *Resolver) VisitExpressionStmt(stmt Expression) interface{} {
+	r.expr(stmt.Expr)
+	return nil
+}
+
+func (r *Resolver) VisitPrintStmt(stmt Print) interface{} {
+	r.expr(stmt.Expr)
+	return nil
+}
+
+func (r *Resolver) VisitVarStmt(stmt Var) interface{} {
+	r.expr(stmt.Initializer)
+	r.declare(stmt.Name)
+	return nil
+}
+
+func (r *Resolver) VisitBlockStmt(stmt Block) interface{} {
+	r.block(stmt.Statements)
+	return nil
+}
+
+func (r *Resolver) VisitIfStmt(stmt If) interface{} {
+	r.expr(stmt.Condition)
+	r.stmt(stmt.ThenBranch)
+	r.stmt(stmt.ElseBranch)
+	return nil
+}
+
+func (r *Resolver) VisitWhileStmt(stmt While) interface{} {
+	r.expr(stmt.Condition)
+	r.stmt(stmt.Body)
+	return nil
+}
+
+func (r *Resolver) VisitRecordStmt(stmt Record) interface{} {
+	r.declare(stmt.Name)
+	return nil
+}
+
+func (r *Resolver) VisitTestStmt(stmt Test) interface{} {
+	r.block(stmt.Body)
+	return nil
+}
+
+func (r *Resolver) VisitEmptyStmt(stmt Empty) interface{} {
+	return nil
+}
+
+func (r *Resolver) VisitAssignExpr(expr Assign) interface{} {
+	r.expr(expr.Value)
+	return nil
+}
+
+func (r *Resolver) VisitBinaryExpr(expr Binary) interface{} {
+	r.expr(expr.Left)
+	r.expr(expr.Right)
+	return nil
+}
+
+func (r *Resolver) VisitGroupingExpr(expr Grouping) interface{} {
+	r.expr(expr.Expr)
+	return nil
+}
+
+func (r *Resolver) VisitLiteralExpr(expr Literal) interface{} {
+	return nil
+}
+
+func (r *Resolver) VisitUnaryExpr(expr Unary) interface{} {
+	r.expr(expr.Right)
+	return nil
+}
+
+func (r *Resolver) VisitVariableExpr(expr Variable) interface{} {
+	r.read(expr.Name)
+	return nil
+}
+
+func (r *Resolver) VisitLogicalExpr(expr Logical) interface{} {
+	r.expr(expr.Left)
+	r.expr(expr.Right)
+	return nil
+}
+
+func (r *Resolver) VisitCallExpr(expr Call) interface{} {
+	r.expr(expr.Callee)
+	for _, arg := range expr.Args {
+		r.expr(arg)
+	}
+	return nil
+}
+
+// VisitIsExpr reads the type, it may be a record declared in a block
+func (r *Resolver) VisitIsExpr(expr Is) interface{} {
+	r.expr(expr.Value)
+	r.read(expr.Type)
+	return nil
+}
+
+func (r *Resolver) VisitGetExpr(expr Get) interface{} {
+	r.expr(expr.Object)
+	return nil
+}
+
+func (r *Resolver) VisitWithExpr(expr With) interface{} {
+	r.expr(expr.Object)
+	for _, v := range expr.Values {
+		r.expr(v)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitConditionalExpr(expr Conditional) interface{} {
+	r.expr(expr.Condition)
+	r.expr(expr.ThenBranch)
+	r.expr(expr.ElseBranch)
+	return nil
+}
+
+func (r *Resolver) VisitCompoundExpr(expr Compound) interface{} {
+	r.beginScope()
+	for _, s := range expr.Statements {
+		r.stmt(s)
+	}
+	r.expr(expr.Value)
+	r.endScope()
+	return nil
+}
+
+func (r *Resolver) VisitLoopExpr(expr Loop) interface{} {
+	r.expr(expr.Condition)
+	r.expr(expr.Body)
+	return nil
+}
+
+// Helper
+
+func (r *Resolver) block(stmts []Stmt) {
+	r.beginScope()
+	for _, s := range stmts {
+		r.stmt(s)
+	}
+	r.endScope()
+}
+
+// declare adds a variable to the current scope, globals are not tracked
+// as other scripts and the REPL may read them later
+func (r *Resolver) declare(name Token) {
+	if r.scope == nil {
+		return
+	}
+	// A redeclaration replaces the variable, the first one is still
+	// reported if it was never read
+	v := &resolveVar{name: name}
+	r.scope.order = append(r.scope.order, v)
+	r.scope.vars[name.Lexeme] = v
+}
+
+func (r *Resolver) read(name Token) {
+	for s := r.scope; s != nil; s = s.enclosing {
+		if v, ok := s.vars[name.Lexeme]; ok {
+			v.read = true
+			return
+		}
+	}
+}
+
+func (r *Resolver) beginScope() {
+	r.scope = &resolveScope{enclosing: r.scope, vars: map[string]*resolveVar{}}
+}
+
+// endScope warns about the variables of the scope never read, names
+// starting with '_' are meant to be unused
+func (r *Resolver) endScope() {
+	for _, v := range r.scope.order {
+		if v.read || strings.HasPrefix(v.name.Lexeme, "_") {
+			continue
+		}
+		span := TokenSpan(r.File, v.name)
+		r.Warnings = append(r.Warnings, Diagnostic{
+			Severity:    SeverityWarning,
+			Code:        WarnUnusedLocal,
+			Message:     "Local variable '" + v.name.Lexeme + "' is never read.",
+			Span:        span,
+			Suggestions: []Suggestion{{"prefix it with '_' if it is meant to be unused", span, "_" + v.name.Lexeme}},
+		})
+	}
+	r.scope = r.scope.enclosing
+}
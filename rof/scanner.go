@@ -2,9 +2,9 @@ package rof
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
-
-	"github.com/reloonfire/rof-language/helpers"
+	"unicode/utf8"
 )
 
 type StringLiteral string
@@ -26,15 +26,28 @@ var keywords = map[TokenType]string{
 	FOR:    "for",
 	FUN:    "fun",
 	IF:     "if",
+	IS:     "is",
 	NIL:    "nil",
 	OR:     "or",
 	PRINT:  "print",
+	RECORD: "record",
 	RETURN: "return",
 	SUPER:  "super",
 	THIS:   "this",
 	TRUE:   "true",
 	VAR:    "var",
 	WHILE:  "while",
+	WITH:   "with",
+}
+
+// Keywords - Sorted list of the reserved words
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for _, w := range keywords {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+	return words
 }
 
 // Scanner - Scanner look into the source looking for tokens
@@ -45,16 +58,36 @@ type Scanner struct {
 	Current  int
 	Line     int
 	HadError bool
+	// KeepTrivia attaches whitespace and comments to the next token
+	// instead of discarding them, so the source can be rebuilt
+	KeepTrivia bool
+	trivia     []Trivia
+	// Errors are the errors found, HadError is set when there is one
+	Errors []*ScanError
+	// Diagnostics are the errors and the warnings in source order
+	Diagnostics []Diagnostic
+	// lineStart is the offset of the current line, startLine and
+	// startColumn the position of the token being scanned
+	lineStart   int
+	startLine   int
+	startColumn int
+}
+
+// NewScanner - Create a scanner for source, lines are counted from 1
+func NewScanner(source string) *Scanner {
+	return &Scanner{Source: source, Line: 1}
 }
 
 // Scan - Scan through source looking for tokens
 func (s *Scanner) Scan() []Token {
 	for !s.IsEnd() {
 		s.Start = s.Current
+		s.startLine, s.startColumn = s.Line, s.Current-s.lineStart+1
 		s.scanToken()
 	}
 
-	s.Tokens = append(s.Tokens, Token{TokenType: EOF, Lexeme: "EOF", Literal: nil, Line: s.Line})
+	column := s.Current - s.lineStart + 1
+	s.Tokens = append(s.Tokens, Token{TokenType: EOF, Lexeme: "EOF", Literal: nil, Line: s.Line, Column: column, Offset: s.Current, Leading: s.trivia})
 	return s.Tokens
 }
 
@@ -67,7 +100,26 @@ func (s *Scanner) advance() string {
 // AddToken - Add Token
 func (s *Scanner) addToken(t TokenType, literal interface{}) {
 	lexeme := s.Source[s.Start:s.Current]
-	s.Tokens = append(s.Tokens, Token{TokenType: t, Literal: literal, Line: s.Line, Lexeme: lexeme})
+	s.Tokens = append(s.Tokens, Token{TokenType: t, Literal: literal, Line: s.startLine, Column: s.startColumn, Offset: s.Start, Lexeme: lexeme, Leading: s.trivia})
+	s.trivia = nil
+}
+
+// addTrivia records the text just scanned when trivia are kept,
+// consecutive whitespace is merged
+func (s *Scanner) addTrivia(kind TriviaKind) {
+	if !s.KeepTrivia {
+		return
+	}
+	end := s.Current
+	if end > len(s.Source) {
+		end = len(s.Source)
+	}
+	text := s.Source[s.Start:end]
+	if n := len(s.trivia); kind == Whitespace && n > 0 && s.trivia[n-1].Kind == Whitespace {
+		s.trivia[n-1].Text += text
+		return
+	}
+	s.trivia = append(s.trivia, Trivia{kind, text})
 }
 
 // ScanToken - Scan Token
@@ -87,6 +139,9 @@ func (s *Scanner) scanToken() {
 	case "}":
 		s.addToken(RIGHT_BRACE, nil)
 		break
+	case ":":
+		s.addToken(COLON, nil)
+		break
 	case ",":
 		s.addToken(COMMA, nil)
 		break
@@ -140,26 +195,22 @@ func (s *Scanner) scanToken() {
 			for s.peek() != "\n" && !s.IsEnd() {
 				s.advance()
 			}
+			s.suspicious("comment")
+			s.addTrivia(LineComment)
 		} else if s.match("*") {
-			// Multi Line Comment
-			//fmt.Println("[DEBUG] LINE [", s.Line, "] START OF MULTILINE COMMENT")
-			for s.peek() != "*" && s.peekNext() != "/" && !s.IsEnd() {
-				s.advance()
-			}
-			s.Current += 2
-			//fmt.Println("[DEBUG] LINE [", s.Line, "] END OF MULTILINE COMMENT")
+			s.blockComment()
 		} else {
 			s.addToken(SLASH, nil)
 		}
 		break
-	case " ":
-	case "\r":
-	case "\t":
+	case " ", "\r", "\t":
 		// Ignore whitespace.
+		s.addTrivia(Whitespace)
 		break
 	case "\n":
 		//fmt.Println("[DEBUG] LINE [", s.Line, "] NEW LINE")
-		s.Line++
+		s.newLine()
+		s.addTrivia(Newline)
 		break
 	case "\"":
 		s.string()
@@ -170,12 +221,126 @@ func (s *Scanner) scanToken() {
 		} else if s.isAlpha(c) {
 			s.identifier()
 		} else {
-			helpers.ReportError(s.Line, "Unexpected character.")
+			// The whole character is reported, not each of its bytes.
+			for !s.IsEnd() && !utf8.RuneStart(s.Source[s.Current]) {
+				s.Current++
+			}
+			s.error(CodeUnexpectedCharacter, "Unexpected character.", "")
 		}
 		break
 	}
 }
 
+// blockComment skips a comment up to its closing '*/', comments nest so
+// that code holding comments can be commented out
+func (s *Scanner) blockComment() {
+	depth := 1
+	for depth > 0 && !s.IsEnd() {
+		switch {
+		case s.peek() == "/" && s.peekNext() == "*":
+			s.Current += 2
+			depth++
+		case s.peek() == "*" && s.peekNext() == "/":
+			s.Current += 2
+			depth--
+		case s.peek() == "\n":
+			s.advance()
+			s.newLine()
+		default:
+			s.advance()
+		}
+	}
+	if depth > 0 {
+		s.unterminated(CodeUnterminatedComment, "block comment", "/*", "*/")
+	}
+	s.suspicious("comment")
+	s.addTrivia(BlockComment)
+}
+
+func (s *Scanner) newLine() {
+	s.Line++
+	s.lineStart = s.Current
+}
+
+// error reports a problem with the token being scanned, label is
+// shown under it by the diagnostics
+func (s *Scanner) error(code, message, label string) {
+	end := Position{Line: s.Line, Column: s.Current - s.lineStart + 1, Offset: s.Current}
+	s.errorTo(end, &ScanError{Message: message, Code: code, Label: label})
+}
+
+// unterminated reports a string or comment running to the end of the
+// file, only its opening delimiter is marked
+func (s *Scanner) unterminated(code, what, delimiter, closing string) {
+	end := Position{Line: s.startLine, Column: s.startColumn + len(delimiter), Offset: s.Start + len(delimiter)}
+	s.errorTo(end, &ScanError{
+		Message: "Unterminated " + what + ".",
+		Code:    code,
+		Label:   what + " starts here",
+		Help:    []string{"add the closing '" + closing + "'"},
+	})
+}
+
+func (s *Scanner) errorTo(end Position, err *ScanError) {
+	err.Span = Span{Position{Line: s.startLine, Column: s.startColumn, Offset: s.Start}, end}
+	s.Errors = append(s.Errors, err)
+	s.Diagnostics = append(s.Diagnostics, err.Diagnostic())
+	s.HadError = true
+}
+
+// suspiciousCharacters are invisible or change the direction of the
+// text, so that code may not read as it runs
+var suspiciousCharacters = map[rune]string{
+	'\u00ad': "SOFT HYPHEN",
+	'\u061c': "ARABIC LETTER MARK",
+	'\u200b': "ZERO WIDTH SPACE",
+	'\u200c': "ZERO WIDTH NON-JOINER",
+	'\u200d': "ZERO WIDTH JOINER",
+	'\u200e': "LEFT-TO-RIGHT MARK",
+	'\u200f': "RIGHT-TO-LEFT MARK",
+	'\u202a': "LEFT-TO-RIGHT EMBEDDING",
+	'\u202b': "RIGHT-TO-LEFT EMBEDDING",
+	'\u202c': "POP DIRECTIONAL FORMATTING",
+	'\u202d': "LEFT-TO-RIGHT OVERRIDE",
+	'\u202e': "RIGHT-TO-LEFT OVERRIDE",
+	'\u2060': "WORD JOINER",
+	'\u2066': "LEFT-TO-RIGHT ISOLATE",
+	'\u2067': "RIGHT-TO-LEFT ISOLATE",
+	'\u2068': "FIRST STRONG ISOLATE",
+	'\u2069': "POP DIRECTIONAL ISOLATE",
+	'\ufeff': "ZERO WIDTH NO-BREAK SPACE",
+}
+
+// suspicious warns about the suspicious characters of the string or
+// comment just scanned
+func (s *Scanner) suspicious(what string) {
+	end := s.Current
+	if end > len(s.Source) {
+		end = len(s.Source)
+	}
+	line, lineStart := s.startLine, s.Start-s.startColumn+1
+	for n, c := range s.Source[s.Start:end] {
+		offset := s.Start + n
+		if c == '\n' {
+			line, lineStart = line+1, offset+1
+			continue
+		}
+		name, ok := suspiciousCharacters[c]
+		if !ok {
+			continue
+		}
+		start := Position{Line: line, Column: offset - lineStart + 1, Offset: offset}
+		end := Position{Line: line, Column: start.Column + utf8.RuneLen(c), Offset: offset + utf8.RuneLen(c)}
+		s.Diagnostics = append(s.Diagnostics, Diagnostic{
+			Severity: SeverityWarning,
+			Code:     WarnSuspiciousCharacter,
+			Message:  fmt.Sprintf("Suspicious character U+%04X %s in %s.", c, name, what),
+			Span:     Span{start, end},
+			Help:     []string{"remove it unless it is meant to be there, most editors do not show it"},
+		})
+	}
+}
+
 func (s *Scanner) isAlpha(c string) bool {
 	return c >= "a" && c <= "z" || c >= "A" && c <= "Z" || c == "_"
 }
@@ -219,7 +384,7 @@ func (s *Scanner) number() {
 	}
 	f, err := strconv.ParseFloat(s.Source[s.Start:s.Current], 64)
 	if err != nil {
-		helpers.ReportError(s.Line, "Cannot convert to float.")
+		s.error(CodeInvalidNumber, "Cannot convert to float.", "")
 	}
 	s.addToken(NUMBER, f)
 }
@@ -234,19 +399,22 @@ func (s *Scanner) peekNext() string {
 func (s *Scanner) string() {
 	for s.peek() != "\"" && !s.IsEnd() {
 		if s.peek() == "\n" {
-			s.Line++
+			s.advance()
+			s.newLine()
+			continue
 		}
 		s.advance()
 	}
 
 	// Unterminated string.
 	if s.IsEnd() {
-		helpers.ReportError(s.Line, "Unterminated string.")
+		s.unterminated(CodeUnterminatedString, "string", `"`, `"`)
 		return
 	}
 
 	// The closing ".
 	s.advance()
+	s.suspicious("string")
 
 	// Trim the surrounding quotes.
 	value := string(s.Source[s.Start+1 : s.Current-1])
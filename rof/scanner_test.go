@@ -0,0 +1,69 @@
+package rof
+
+import "testing"
+
+func TestScanComments(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []TokenType
+	}{
+		{"// line\nprint 1;", []TokenType{PRINT, NUMBER, SEMICOLON, EOF}},
+		{"/* block */ print 1;", []TokenType{PRINT, NUMBER, SEMICOLON, EOF}},
+		{"/* a /* b */ c */ print 1;", []TokenType{PRINT, NUMBER, SEMICOLON, EOF}},
+		{"/* /* /* deep */ */ */ 1", []TokenType{NUMBER, EOF}},
+		{"/* // not a line comment */ 1", []TokenType{NUMBER, EOF}},
+		{"1 /* a */ / /* b */ 2", []TokenType{NUMBER, SLASH, NUMBER, EOF}},
+	}
+	for _, tt := range tests {
+		sc := NewScanner(tt.source)
+		tokens := sc.Scan()
+		if sc.HadError {
+			t.Errorf("%q: %v", tt.source, sc.Errors)
+			continue
+		}
+		var got []TokenType
+		for _, token := range tokens {
+			got = append(got, token.TokenType)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: tokens %v, want %v", tt.source, got, tt.want)
+			continue
+		}
+		for n := range got {
+			if got[n] != tt.want[n] {
+				t.Errorf("%q: tokens %v, want %v", tt.source, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		code   string
+		line   int
+		column int
+	}{
+		{"/* a /* b */ print 1;", CodeUnterminatedComment, 1, 1},
+		{"print 1;\n/* open", CodeUnterminatedComment, 2, 1},
+		{"\"abc", CodeUnterminatedString, 1, 1},
+		{"print 1 @ 2;", CodeUnexpectedCharacter, 1, 9},
+		{"print \"é\" é;", CodeUnexpectedCharacter, 1, 12},
+	}
+	for _, tt := range tests {
+		sc := NewScanner(tt.source)
+		sc.Scan()
+		if !sc.HadError || len(sc.Errors) != 1 {
+			t.Errorf("%q: errors %v, want one %s", tt.source, sc.Errors, tt.code)
+			continue
+		}
+		err := sc.Errors[0]
+		if err.Code != tt.code || err.Span.Start.Line != tt.line || err.Span.Start.Column != tt.column {
+			t.Errorf("%q: %s at %d:%d, want %s at %d:%d", tt.source, err.Code, err.Span.Start.Line, err.Span.Start.Column, tt.code, tt.line, tt.column)
+		}
+		if len(sc.Diagnostics) != 1 || sc.Diagnostics[0].Code != tt.code {
+			t.Errorf("%q: diagnostics %v, want the error", tt.source, sc.Diagnostics)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package rof
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position - Place in a source file. Line and Column start at 1 and
+// Offset at 0, Column and Offset count bytes.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+	Offset int
+}
+
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Span - Source range of a node, End is just past its last character.
+// Nodes built by hand or decoded from old JSON trees have a zero span.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) IsZero() bool {
+	return s.Start.Line == 0
+}
+
+func (s Span) String() string {
+	return fmt.Sprintf("%v-%d:%d", s.Start, s.End.Line, s.End.Column)
+}
+
+// TokenSpan returns the range of the lexeme of a token
+func TokenSpan(file string, t Token) Span {
+	start := Position{file, t.Line, t.Column, t.Offset}
+	end := start
+	end.Offset += len(t.Lexeme)
+	if n := strings.LastIndex(t.Lexeme, "\n"); n >= 0 {
+		end.Line += strings.Count(t.Lexeme, "\n")
+		end.Column = len(t.Lexeme) - n
+	} else {
+		end.Column += len(t.Lexeme)
+	}
+	return Span{start, end}
+}
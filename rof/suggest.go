@@ -0,0 +1,85 @@
+package rof
+
+import "sort"
+
+// Suggestion - Fix proposed by a diagnostic, Replacement is the text to
+// put in place of Span
+type Suggestion struct {
+	Message     string
+	Span        Span
+	Replacement string
+}
+
+// closest returns the candidates near enough to name to be a typo of it,
+// the nearest first. A candidate may be one edit away per three
+// characters of name, swapping two letters counts as one edit.
+func closest(name string, candidates []string, limit int) []string {
+	max := len(name) / 3
+	if max < 1 {
+		max = 1
+	}
+	distances := map[string]int{}
+	var found []string
+	for _, c := range candidates {
+		if _, ok := distances[c]; ok || c == name {
+			continue
+		}
+		d := editDistance(name, c)
+		if d <= max && d < len(name) {
+			distances[c] = d
+			found = append(found, c)
+		}
+	}
+	sort.Slice(found, func(a, b int) bool {
+		x, y := found[a], found[b]
+		return distances[x] < distances[y] || distances[x] == distances[y] && x < y
+	})
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found
+}
+
+// editDistance is the optimal string alignment distance of a and b, the
+// number of insertions, deletions, substitutions and transpositions of
+// adjacent bytes turning a into b
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, minInt(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// suggestNames returns the suggestions replacing a misspelled name by
+// the closest candidates
+func suggestNames(name Token, candidates []string) []Suggestion {
+	var suggestions []Suggestion
+	for _, c := range closest(name.Lexeme, candidates, 3) {
+		suggestions = append(suggestions, Suggestion{"did you mean '" + c + "'?", errorSpan(name), c})
+	}
+	return suggestions
+}
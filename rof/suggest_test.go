@@ -0,0 +1,55 @@
+package rof
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "abc", 0},
+		{"print", "prnt", 1},
+		{"print", "pirnt", 1},
+		{"while", "whiel", 1},
+		{"var", "vra", 1},
+		{"record", "recrod", 1},
+		{"kitten", "sitting", 3},
+		{"ca", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestClosest(t *testing.T) {
+	candidates := []string{"print", "printf", "var", "while", "count", "counter", "x"}
+	tests := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{"pirnt", 3, []string{"print"}},
+		{"printt", 3, []string{"print", "printf"}},
+		{"printt", 1, []string{"print"}},
+		{"whiel", 3, []string{"while"}},
+		{"cuont", 3, []string{"count"}},
+		{"y", 3, nil},
+		{"print", 3, []string{"printf"}},
+		{"zzzzz", 3, nil},
+	}
+	for _, tt := range tests {
+		if got := closest(tt.name, candidates, tt.limit); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("closest(%q, %d) = %q, want %q", tt.name, tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package rof
+
+import (
+	"bytes"
+	"time"
+)
+
+// TestResult - Outcome of a test block, Err is nil when it passed
+type TestResult struct {
+	Name     string
+	Line     int
+	Err      error
+	Output   string
+	Duration time.Duration
+}
+
+func (r TestResult) Failed() bool {
+	return r.Err != nil
+}
+
+// Tests returns the test blocks of a file
+func Tests(stmts []Stmt) []Test {
+	var tests []Test
+	for _, stmt := range stmts {
+		if t, ok := stmt.(Test); ok {
+			tests = append(tests, t)
+		}
+	}
+	return tests
+}
+
+// RunTests runs the tests of a file whose name match. Every test gets a
+// fresh interpreter running the rest of the file first, so tests cannot
+// see each other's changes.
+func RunTests(stmts []Stmt, match func(name string) bool) []TestResult {
+	var results []TestResult
+	for _, test := range Tests(stmts) {
+		name := test.Name.Literal.(string)
+		if match != nil && !match(name) {
+			continue
+		}
+
+		var out bytes.Buffer
+		i := NewInterpreter()
+		i.DefineArgs(nil)
+		i.Out = &out
+		start := time.Now()
+		err := i.Interpret(stmts)
+		if err == nil {
+			err = i.Interpret([]Stmt{Block{test.Body, test.Span}})
+		}
+		results = append(results, TestResult{name, test.Keyword.Line, err, out.String(), time.Since(start)})
+	}
+	return results
+}
@@ -0,0 +1,72 @@
+package rof
+
+import "testing"
+
+func TestRunTests(t *testing.T) {
+	source := `var total = 10;
+print "setup";
+test "adds" {
+  total = total + 2;
+  assertEqual(12, total);
+}
+test "sees a fresh total" {
+  assertEqual(10, total);
+}
+test "fails" {
+  print "before";
+  assert(total > 10);
+}
+test "errors" {
+  print -"a";
+}
+test "throws" {
+  assertThrows(argv, -1);
+  assertThrows(clock);
+}
+`
+	stmts, errs := parse(t, source)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	tests := []struct {
+		name   string
+		line   int
+		err    string
+		output string
+	}{
+		{"adds", 3, "", "setup\n"},
+		{"sees a fresh total", 7, "", "setup\n"},
+		{"fails", 10, "Assertion failed, got false.", "setup\nbefore\n"},
+		{"errors", 14, "Operand must be number", "setup\n"},
+		{"throws", 17, "Expected clock to fail.", "setup\n"},
+	}
+	results := RunTests(stmts, nil)
+	if len(results) != len(tests) {
+		t.Fatalf("%d results, want %d", len(results), len(tests))
+	}
+	for n, tt := range tests {
+		r := results[n]
+		message := ""
+		if r.Err != nil {
+			message = DiagnosticOf(r.Err).Message
+		}
+		if r.Name != tt.name || r.Line != tt.line || message != tt.err || r.Output != tt.output || r.Failed() != (tt.err != "") {
+			t.Errorf("result %d: %q line %d error %q output %q, want %q line %d error %q output %q",
+				n, r.Name, r.Line, message, r.Output, tt.name, tt.line, tt.err, tt.output)
+		}
+	}
+	if _, ok := results[2].Err.(*AssertionError); !ok {
+		t.Errorf("failed assertion gives %T, want *AssertionError", results[2].Err)
+	}
+
+	results = RunTests(stmts, func(name string) bool { return name == "fails" || name == "adds" })
+	if len(results) != 2 || results[0].Name != "adds" || results[1].Name != "fails" {
+		t.Errorf("matching results %v, want adds and fails", results)
+	}
+
+	stmts, _ = parse(t, "print -nil; test \"setup fails\" { assert(true); }")
+	results = RunTests(stmts, nil)
+	if len(results) != 1 || !results[0].Failed() {
+		t.Errorf("test after a failing setup gives %v, want a failure", results)
+	}
+}
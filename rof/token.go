@@ -9,6 +9,29 @@ type Token struct {
 	Lexeme    string
 	Literal   interface{}
 	Line      int
+	// Column is the byte offset of the token in its line, from 1
+	Column int
+	// Offset is the byte offset of the token in the source, from 0
+	Offset int
+	// Leading holds the whitespace and comments before the token, only
+	// when the scanner keeps trivia
+	Leading []Trivia `json:",omitempty"`
+}
+
+// TriviaKind - Kind of source text that is not part of a token
+type TriviaKind int
+
+const (
+	Whitespace TriviaKind = iota
+	Newline
+	LineComment
+	BlockComment
+)
+
+// Trivia - Whitespace or comment kept by the scanner
+type Trivia struct {
+	Kind TriviaKind
+	Text string
 }
 
 const (
@@ -16,6 +39,7 @@ const (
 	RIGHT_PAREN
 	LEFT_BRACE
 	RIGHT_BRACE
+	COLON
 	COMMA
 	DOT
 	MINUS
@@ -50,15 +74,35 @@ const (
 	FUN
 	FOR
 	IF
+	IS
 	NIL
 	OR
 	PRINT
+	RECORD
 	RETURN
 	SUPER
 	THIS
 	TRUE
 	VAR
 	WHILE
+	WITH
 
 	EOF
 )
+
+var tokenNames = [...]string{
+	"LEFT_PAREN", "RIGHT_PAREN", "LEFT_BRACE", "RIGHT_BRACE", "COLON", "COMMA", "DOT",
+	"MINUS", "PLUS", "SEMICOLON", "SLASH", "STAR",
+	"BANG", "BANG_EQUAL", "EQUAL", "EQUAL_EQUAL", "GREATER", "GREATER_EQUAL", "LESS", "LESS_EQUAL",
+	"IDENTIFIER", "STRING", "NUMBER",
+	"AND", "CLASS", "ELSE", "FALSE", "FUN", "FOR", "IF", "IS", "NIL", "OR", "PRINT", "RECORD",
+	"RETURN", "SUPER", "THIS", "TRUE", "VAR", "WHILE", "WITH",
+	"EOF",
+}
+
+func (t TokenType) String() string {
+	if t < 0 || int(t) >= len(tokenNames) {
+		return "UNKNOWN"
+	}
+	return tokenNames[t]
+}
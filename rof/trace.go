@@ -0,0 +1,108 @@
+package rof
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Tracer - Receives the events of a run, depth is the number of scopes
+// opened since the globals
+type Tracer interface {
+	// Statement is called before each statement other than a block
+	Statement(stmt Stmt, depth int)
+	Define(name Token, value interface{}, depth int)
+	Assign(name Token, old, value interface{}, depth int)
+	// Call is called before a call runs, so that a call that fails is
+	// traced too, and Return when it returns
+	Call(paren Token, callee Callable, args []interface{}, depth int)
+	Return(paren Token, callee Callable, result interface{}, depth int)
+}
+
+// traceTextWidth - Longest statement text of a trace event, loops and
+// blocks would repeat their whole body
+const traceTextWidth = 80
+
+// TraceWriter - Tracer writing one line per event, as text indented by
+// depth or as JSON objects
+type TraceWriter struct {
+	Out  io.Writer
+	File string
+	JSON bool
+	// Last line of a statement, for events of nodes without a line
+	line    int
+	printer ASTPrinter
+}
+
+func NewTraceWriter(out io.Writer, file string, asJSON bool) *TraceWriter {
+	return &TraceWriter{Out: out, File: file, JSON: asJSON}
+}
+
+func (t *TraceWriter) Statement(stmt Stmt, depth int) {
+	if line := StmtLine(stmt); line != 0 {
+		t.line = line
+	}
+	text := truncate(t.printer.PrintStmt(stmt), traceTextWidth)
+	fields := map[string]interface{}{"statement": text}
+	if span := StmtSpan(stmt); !span.IsZero() {
+		fields["span"] = spanObject(span)
+	}
+	t.write(t.line, depth, "statement", text, fields)
+}
+
+func (t *TraceWriter) Define(name Token, value interface{}, depth int) {
+	t.write(name.Line, depth, "define", name.Lexeme+" = "+traceValue(value), map[string]interface{}{"name": name.Lexeme, "value": traceValue(value)})
+}
+
+func (t *TraceWriter) Assign(name Token, old, value interface{}, depth int) {
+	text := name.Lexeme + " = " + traceValue(value) + " (was " + traceValue(old) + ")"
+	t.write(name.Line, depth, "assign", text, map[string]interface{}{"name": name.Lexeme, "old": traceValue(old), "value": traceValue(value)})
+}
+
+func (t *TraceWriter) Call(paren Token, callee Callable, args []interface{}, depth int) {
+	values := make([]string, len(args))
+	for n, a := range args {
+		values[n] = traceValue(a)
+	}
+	text := callee.Name() + "(" + strings.Join(values, ", ") + ")"
+	t.write(paren.Line, depth, "call", text, map[string]interface{}{"callee": callee.Name(), "args": values})
+}
+
+func (t *TraceWriter) Return(paren Token, callee Callable, result interface{}, depth int) {
+	text := callee.Name() + " -> " + traceValue(result)
+	t.write(paren.Line, depth, "return", text, map[string]interface{}{"callee": callee.Name(), "result": traceValue(result)})
+}
+
+func (t *TraceWriter) write(line, depth int, event, text string, fields map[string]interface{}) {
+	if !t.JSON {
+		fmt.Fprintf(t.Out, "%s:%d: %s%s %s\n", t.File, line, strings.Repeat("  ", depth), event, text)
+		return
+	}
+	fields["event"] = event
+	fields["file"] = t.File
+	fields["line"] = line
+	fields["depth"] = depth
+	enc := json.NewEncoder(t.Out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(fields)
+}
+
+// truncate shortens text to width characters, counting code points so
+// that a character is never cut
+func truncate(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
+	}
+	return string(runes[:width-3]) + "..."
+}
+
+// traceValue prints a value as it would be written in source
+func traceValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return Stringify(value)
+}
@@ -0,0 +1,126 @@
+package rof
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// trace runs source with a TraceWriter and returns the trace
+func trace(t *testing.T, source string, asJSON bool) string {
+	t.Helper()
+	stmts, errs := parse(t, source)
+	if len(errs) > 0 {
+		t.Fatalf("%q: %v", source, errs)
+	}
+	var out bytes.Buffer
+	i := NewInterpreter()
+	i.Out = &bytes.Buffer{}
+	i.Tracer = NewTraceWriter(&out, "test.rof", asJSON)
+	i.Interpret(stmts)
+	return out.String()
+}
+
+func TestTraceText(t *testing.T) {
+	source := "var a = 1;\n{ a = \"x\"; }\nrecord P(x);\nprint typeof(P(a));\nprint clock(1);"
+	want := `test.rof:1: statement (var a 1)
+test.rof:1: define a = 1
+test.rof:2:   statement (expr (= a "x"))
+test.rof:2:   assign a = "x" (was 1)
+test.rof:3: statement (record P x)
+test.rof:3: define P = <record P>
+test.rof:4: statement (print (call typeof (call P a)))
+test.rof:4: call P("x")
+test.rof:4: return P -> P(x: "x")
+test.rof:4: call typeof(P(x: "x"))
+test.rof:4: return typeof -> "P"
+test.rof:5: statement (print (call clock 1))
+test.rof:5: call clock(1)
+`
+	if got := trace(t, source, false); got != want {
+		t.Errorf("trace\n%s\nwant\n%s", got, want)
+	}
+
+	long := "print \"" + strings.Repeat("é", 100) + "\";"
+	got := trace(t, long, false)
+	text := strings.TrimSuffix(strings.TrimPrefix(got, "test.rof:1: statement "), "\n")
+	if n := len([]rune(text)); n != traceTextWidth || !strings.HasSuffix(text, "...") {
+		t.Errorf("statement of %d characters %q, want %d ending with ...", n, text, traceTextWidth)
+	}
+}
+
+func TestTraceJSON(t *testing.T) {
+	got := trace(t, "var a = 1;\n{ a = \"x\"; }\nprint typeof(a);", true)
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	want := []map[string]interface{}{
+		{"event": "statement", "line": 1.0, "depth": 0.0, "statement": "(var a 1)"},
+		{"event": "define", "line": 1.0, "name": "a", "value": "1"},
+		{"event": "statement", "line": 2.0, "depth": 1.0, "statement": `(expr (= a "x"))`},
+		{"event": "assign", "line": 2.0, "depth": 1.0, "name": "a", "old": "1", "value": `"x"`},
+		{"event": "statement", "line": 3.0, "statement": "(print (call typeof a))"},
+		{"event": "call", "callee": "typeof", "args": []interface{}{`"x"`}},
+		{"event": "return", "callee": "typeof", "result": `"string"`},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("trace has %d events, want %d:\n%s", len(lines), len(want), got)
+	}
+	for n, line := range lines {
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		if event["file"] != "test.rof" {
+			t.Errorf("%s: file %v", line, event["file"])
+		}
+		for key, value := range want[n] {
+			if got, _ := json.Marshal(event[key]); string(got) != mustMarshal(t, value) {
+				t.Errorf("%s: %s is %s, want %s", line, key, got, mustMarshal(t, value))
+			}
+		}
+	}
+
+	var statement struct {
+		Span struct {
+			Start, End struct{ Line, Column, Offset int }
+		}
+	}
+	json.Unmarshal([]byte(lines[2]), &statement)
+	if s := statement.Span; s.Start.Column != 3 || s.End.Column != 11 || s.Start.Offset != 13 {
+		t.Errorf("span of the assignment %+v, want columns 3-11 from offset 13", s)
+	}
+	if strings.Contains(lines[1], "span") {
+		t.Errorf("define event has a span: %s", lines[1])
+	}
+}
+
+func mustMarshal(t *testing.T, value interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{"abc", 5, "abc"},
+		{"abcde", 5, "abcde"},
+		{"abcdef", 5, "ab..."},
+		{"héllo", 5, "héllo"},
+		{"héllo wörld", 8, "héllo..."},
+		{"日本語のテキスト", 6, "日本語..."},
+		{"a😀b😀c😀", 5, "a😀..."},
+		{"", 5, ""},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.text, tt.width); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}
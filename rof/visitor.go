@@ -0,0 +1,34 @@
+// Code generated by tool/generate_ast.go from ast.spec; DO NOT EDIT.
+
+package rof
+
+// ExprVisitor - Operation over every kind of Expr, called by Accept
+type ExprVisitor interface {
+	VisitAssignExpr(expr Assign) interface{}
+	VisitBinaryExpr(expr Binary) interface{}
+	VisitGroupingExpr(expr Grouping) interface{}
+	VisitLiteralExpr(expr Literal) interface{}
+	VisitUnaryExpr(expr Unary) interface{}
+	VisitVariableExpr(expr Variable) interface{}
+	VisitLogicalExpr(expr Logical) interface{}
+	VisitCallExpr(expr Call) interface{}
+	VisitIsExpr(expr Is) interface{}
+	VisitGetExpr(expr Get) interface{}
+	VisitWithExpr(expr With) interface{}
+	VisitConditionalExpr(expr Conditional) interface{}
+	VisitCompoundExpr(expr Compound) interface{}
+	VisitLoopExpr(expr Loop) interface{}
+}
+
+// StmtVisitor - Operation over every kind of Stmt, called by Accept
+type StmtVisitor interface {
+	VisitExpressionStmt(stmt Expression) interface{}
+	VisitPrintStmt(stmt Print) interface{}
+	VisitVarStmt(stmt Var) interface{}
+	VisitBlockStmt(stmt Block) interface{}
+	VisitIfStmt(stmt If) interface{}
+	VisitWhileStmt(stmt While) interface{}
+	VisitRecordStmt(stmt Record) interface{}
+	VisitTestStmt(stmt Test) interface{}
+	VisitEmptyStmt(stmt Empty) interface{}
+}
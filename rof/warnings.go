@@ -0,0 +1,137 @@
+package rof
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Warning names, the code of their diagnostics
+const (
+	WarnSuspiciousCharacter = "suspicious-character"
+	WarnEmptyStatement      = "empty-statement"
+	WarnUnusedLocal         = "unused-local"
+)
+
+// Warnings - Every warning with a short description
+var Warnings = map[string]string{
+	WarnSuspiciousCharacter: "invisible or text direction characters in strings and comments",
+	WarnEmptyStatement:      "lone ';' doing nothing",
+	WarnUnusedLocal:         "variables of a block that are never read",
+}
+
+// WarningOptions - Warnings turned off or into errors, set from the
+// command line one option at a time with Set
+type WarningOptions struct {
+	Disabled map[string]bool
+	Errors   map[string]bool
+	// AllErrors turns every enabled warning into an error
+	AllErrors bool
+}
+
+// Set applies an option: 'name' enables a warning and 'no-name'
+// disables it, 'all' and 'none' do it for every warning, 'error' turns
+// the warnings into errors and 'error=name' only one of them
+func (o *WarningOptions) Set(option string) error {
+	if o.Disabled == nil {
+		o.Disabled, o.Errors = map[string]bool{}, map[string]bool{}
+	}
+	switch {
+	case option == "all" || option == "none":
+		for name := range Warnings {
+			o.Disabled[name] = option == "none"
+		}
+		return nil
+	case option == "error":
+		o.AllErrors = true
+		return nil
+	case strings.HasPrefix(option, "error="):
+		name := strings.TrimPrefix(option, "error=")
+		if _, ok := Warnings[name]; !ok {
+			return fmt.Errorf("unknown warning %q", name)
+		}
+		o.Errors[name] = true
+		o.Disabled[name] = false
+		return nil
+	}
+	name := strings.TrimPrefix(option, "no-")
+	if _, ok := Warnings[name]; !ok {
+		return fmt.Errorf("unknown warning %q", name)
+	}
+	o.Disabled[name] = name != option
+	return nil
+}
+
+func (o *WarningOptions) String() string {
+	var options []string
+	for name, off := range o.Disabled {
+		if off {
+			options = append(options, "no-"+name)
+		}
+	}
+	for name := range o.Errors {
+		options = append(options, "error="+name)
+	}
+	if o.AllErrors {
+		options = append(options, "error")
+	}
+	sort.Strings(options)
+	return strings.Join(options, ",")
+}
+
+// Sink - Collects the diagnostics of a script. The warnings disabled by
+// the options or by a '// rof:allow(name, ...)' comment on their line
+// or the line before are dropped, the promoted ones become errors.
+type Sink struct {
+	Options     WarningOptions
+	Diagnostics []Diagnostic
+	allowed     map[int]map[string]bool
+}
+
+// NewSink returns a sink for a script, the tokens must have been
+// scanned with KeepTrivia for the comments to be seen
+func NewSink(options WarningOptions, tokens []Token) *Sink {
+	allowed := directiveLines(tokens, func(text string) ([]string, bool) {
+		text = strings.TrimSpace(text)
+		if !strings.HasPrefix(text, "rof:allow(") || !strings.HasSuffix(text, ")") {
+			return nil, false
+		}
+		names := strings.Split(strings.TrimSuffix(strings.TrimPrefix(text, "rof:allow("), ")"), ",")
+		for n := range names {
+			names[n] = strings.TrimSpace(names[n])
+		}
+		return names, true
+	})
+	return &Sink{Options: options, allowed: allowed}
+}
+
+// Report adds a diagnostic, it returns false when it was dropped
+func (s *Sink) Report(d Diagnostic) bool {
+	if d.Severity == SeverityWarning {
+		if s.Options.Disabled[d.Code] || s.allowed[d.Span.Start.Line][d.Code] {
+			return false
+		}
+		if s.Options.AllErrors || s.Options.Errors[d.Code] {
+			d.Severity = SeverityError
+			option := "error=" + d.Code
+			if s.Options.AllErrors {
+				option = "error"
+			}
+			d.Notes = append(d.Notes[:len(d.Notes):len(d.Notes)], "the "+d.Code+" warning is an error with -W "+option)
+		}
+	}
+	s.Diagnostics = append(s.Diagnostics, d)
+	return true
+}
+
+// Errors returns the number of errors reported, promoted warnings
+// included
+func (s *Sink) Errors() int {
+	n := 0
+	for _, d := range s.Diagnostics {
+		if d.Severity == SeverityError {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,114 @@
+package rof
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWarningOptionsSet(t *testing.T) {
+	tests := []struct {
+		options []string
+		want    string
+		err     string
+	}{
+		{nil, "", ""},
+		{[]string{"no-unused-local"}, "no-unused-local", ""},
+		{[]string{"no-unused-local", "unused-local"}, "", ""},
+		{[]string{"none"}, "no-empty-statement,no-suspicious-character,no-unused-local", ""},
+		{[]string{"none", "empty-statement"}, "no-suspicious-character,no-unused-local", ""},
+		{[]string{"all"}, "", ""},
+		{[]string{"error"}, "error", ""},
+		{[]string{"no-empty-statement", "error=empty-statement"}, "error=empty-statement", ""},
+		{[]string{"shadowing"}, "", `unknown warning "shadowing"`},
+		{[]string{"no-shadowing"}, "", `unknown warning "shadowing"`},
+		{[]string{"error=shadowing"}, "", `unknown warning "shadowing"`},
+	}
+	for _, tt := range tests {
+		var o WarningOptions
+		var err error
+		for _, option := range tt.options {
+			if err = o.Set(option); err != nil {
+				break
+			}
+		}
+		message := ""
+		if err != nil {
+			message = err.Error()
+		}
+		if message != tt.err {
+			t.Errorf("%q: error %v, want %q", tt.options, err, tt.err)
+			continue
+		}
+		if err == nil && o.String() != tt.want {
+			t.Errorf("%q: options %q, want %q", tt.options, o.String(), tt.want)
+		}
+	}
+}
+
+// warnings returns the diagnostics of a script as reported by a sink,
+// one "line:code:severity" per diagnostic
+func warnings(t *testing.T, source string, options ...string) []string {
+	t.Helper()
+	var o WarningOptions
+	for _, option := range options {
+		if err := o.Set(option); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sc := NewScanner(source)
+	sc.KeepTrivia = true
+	tokens := sc.Scan()
+	sink := NewSink(o, tokens)
+	for _, d := range sc.Diagnostics {
+		sink.Report(d)
+	}
+	parser := Parser{Tokens: tokens, Quiet: true}
+	stmts, errs := parser.Parse()
+	if len(errs) > 0 {
+		t.Fatalf("%q: %v", source, errs)
+	}
+	for _, d := range parser.Warnings {
+		sink.Report(d)
+	}
+	for _, d := range (&Resolver{}).Resolve(stmts) {
+		sink.Report(d)
+	}
+	var got []string
+	for _, d := range sink.Diagnostics {
+		got = append(got, fmt.Sprintf("%d:%s:%s", d.Span.Start.Line, d.Code, d.Severity))
+	}
+	return got
+}
+
+func TestSink(t *testing.T) {
+	tests := []struct {
+		source  string
+		options []string
+		want    []string
+	}{
+		{"print 1;;", nil, []string{"1:empty-statement:warning"}},
+		{"print 1;;", []string{"no-empty-statement"}, nil},
+		{"print 1;;", []string{"none"}, nil},
+		{"print 1;;", []string{"error"}, []string{"1:empty-statement:error"}},
+		{"print 1;;", []string{"error=empty-statement"}, []string{"1:empty-statement:error"}},
+		{"print 1;;", []string{"error=unused-local"}, []string{"1:empty-statement:warning"}},
+		{"{\n  var a = 1;\n}", nil, []string{"2:unused-local:warning"}},
+		{"{\n  var _a = 1;\n}", nil, nil},
+		{"{\n  var a = 1;\n  print a;\n}", nil, nil},
+		{"{\n  var a = 1;\n  a = 2;\n}", nil, []string{"2:unused-local:warning"}},
+		{"var a = 1;", nil, nil},
+		{"{\n  var a = 1; // rof:allow(unused-local)\n}", nil, nil},
+		{"{\n  // rof:allow(unused-local)\n  var a = 1;\n}", nil, nil},
+		{"{\n  // rof:allow(empty-statement, unused-local)\n  var a = 1;;\n}", nil, nil},
+		{"{\n  var a = 1; // rof:allow(empty-statement)\n}", nil, []string{"2:unused-local:warning"}},
+		{"// rof:allow(unused-local)\n{\n  var a = 1;\n}", nil, []string{"3:unused-local:warning"}},
+		{"print \"a\u200bb\";", nil, []string{"1:suspicious-character:warning"}},
+	}
+	for _, tt := range tests {
+		got := warnings(t, tt.source, tt.options...)
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%q %q: got %q, want %q", tt.source, tt.options, got, tt.want)
+		}
+	}
+}
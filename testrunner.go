@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/reloonfire/rof-language/helpers"
+	"github.com/reloonfire/rof-language/rof"
+)
+
+// exitTestFailures is returned by 'test' when a test fails
+const exitTestFailures = 1
+
+// testFile - Results of the tests of one file
+type testFile struct {
+	Path    string
+	Source  string
+	Results []rof.TestResult
+}
+
+func testCmd(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	run := flags.String("run", "", "only run the tests whose name match `regexp`")
+	format := flags.String("format", "text", "output `format`, text, tap or junit")
+	addDiagnosticsFlag(flags)
+	addWarningsFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if !validDiagnosticsFormat() {
+		return exitUsage
+	}
+	if *format != "text" && *format != "tap" && *format != "junit" {
+		fmt.Fprintf(os.Stderr, "rof: unknown test format %q\n", *format)
+		return exitUsage
+	}
+	var match func(string) bool
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rof: invalid -run:", err)
+			return exitUsage
+		}
+		match = re.MatchString
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := findTestFiles(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rof:", err)
+		return exitIOError
+	}
+
+	code := exitOK
+	var results []testFile
+	for _, path := range files {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rof:", err)
+			return exitIOError
+		}
+		stmts, c := parse(path, string(b))
+		if c != exitOK {
+			fmt.Fprintf(os.Stderr, "rof: %s: tests not run\n", path)
+			code = maxCode(code, c)
+			continue
+		}
+		file := testFile{path, string(b), rof.RunTests(stmts, match)}
+		for _, r := range file.Results {
+			if r.Failed() {
+				code = maxCode(code, exitTestFailures)
+			}
+		}
+		results = append(results, file)
+	}
+
+	switch *format {
+	case "tap":
+		writeTAP(os.Stdout, results)
+	case "junit":
+		if err := writeJUnit(os.Stdout, results); err != nil {
+			fmt.Fprintln(os.Stderr, "rof:", err)
+			return exitIOError
+		}
+	default:
+		writeTestText(os.Stdout, results, helpers.IsTerminal(os.Stdout))
+	}
+	return code
+}
+
+// findTestFiles returns the files given and the *_test.rof files in the
+// directories given
+func findTestFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(p, "_test.rof") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// writeTestText prints a line per test, the failures are followed by
+// their diagnostic and the output of the test
+func writeTestText(w io.Writer, files []testFile, color bool) {
+	total, failed := 0, 0
+	for _, f := range files {
+		for _, r := range f.Results {
+			total++
+			if !r.Failed() {
+				fmt.Fprintf(w, "PASS %s:%d: %s (%.3fs)\n", f.Path, r.Line, r.Name, r.Duration.Seconds())
+				continue
+			}
+			failed++
+			fmt.Fprintf(w, "FAIL %s:%d: %s (%.3fs)\n", f.Path, r.Line, r.Name, r.Duration.Seconds())
+			var diagnostic strings.Builder
+			rof.NewRenderer(&diagnostic, f.Path, f.Source, color).Render(rof.DiagnosticOf(r.Err).InFile(f.Path))
+			for _, line := range strings.Split(strings.TrimSuffix(diagnostic.String(), "\n\n"), "\n") {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(r.Output, "\n"), "\n") {
+				if line != "" {
+					fmt.Fprintf(w, "    | %s\n", line)
+				}
+			}
+		}
+	}
+	fmt.Fprintf(w, "%d passed, %d failed\n", total-failed, failed)
+}
+
+// writeTAP writes the results in the Test Anything Protocol version 13,
+// the failure details are in YAML blocks
+func writeTAP(w io.Writer, files []testFile) {
+	fmt.Fprintln(w, "TAP version 13")
+	n := 0
+	for _, f := range files {
+		for _, r := range f.Results {
+			n++
+			if !r.Failed() {
+				fmt.Fprintf(w, "ok %d - %s: %s\n", n, f.Path, r.Name)
+				continue
+			}
+			fmt.Fprintf(w, "not ok %d - %s: %s\n", n, f.Path, r.Name)
+			fmt.Fprintln(w, "  ---")
+			fmt.Fprintf(w, "  message: %q\n", r.Err.Error())
+			fmt.Fprintf(w, "  at: %q\n", fmt.Sprintf("%s:%d", f.Path, r.Line))
+			if r.Output != "" {
+				fmt.Fprintf(w, "  output: %q\n", r.Output)
+			}
+			fmt.Fprintln(w, "  ...")
+		}
+	}
+	fmt.Fprintf(w, "1..%d\n", n)
+}
+
+// JUnit XML as read by most CI servers, a suite per file
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit reports failed assertions as failures and the other runtime
+// errors as errors
+func writeJUnit(w io.Writer, files []testFile) error {
+	var report junitSuites
+	for _, f := range files {
+		suite := junitSuite{Name: f.Path}
+		var elapsed time.Duration
+		for _, r := range f.Results {
+			elapsed += r.Duration
+			c := junitCase{Name: r.Name, ClassName: f.Path, Time: seconds(r.Duration), SystemOut: r.Output}
+			if r.Failed() {
+				problem := &junitProblem{r.Err.Error(), "RuntimeError", fmt.Sprintf("%s:%d: %v", f.Path, r.Line, r.Err)}
+				if _, ok := r.Err.(*rof.AssertionError); ok {
+					problem.Type = "AssertionError"
+					c.Failure = problem
+					suite.Failures++
+				} else {
+					c.Error = problem
+					suite.Errors++
+				}
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+		suite.Tests = len(f.Results)
+		suite.Time = seconds(elapsed)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Suites = append(report.Suites, suite)
+	}
+
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
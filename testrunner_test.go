@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/reloonfire/rof-language/rof"
+)
+
+// testResults runs the tests of a file with every duration set to 1ms
+func testResults(t *testing.T, path, source string) testFile {
+	t.Helper()
+	sc := rof.NewScanner(source)
+	parser := rof.Parser{File: path, Tokens: sc.Scan(), Quiet: true}
+	stmts, errs := parser.Parse()
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	file := testFile{path, source, rof.RunTests(stmts, nil)}
+	for n := range file.Results {
+		file.Results[n].Duration = time.Millisecond
+	}
+	return file
+}
+
+var testSource = `test "passes" {
+  assert(true);
+}
+test "fails" {
+  print "out";
+  assertEqual(1, 2);
+}
+test "errors" {
+  print -"a";
+}
+`
+
+func TestWriteTAP(t *testing.T) {
+	var out bytes.Buffer
+	writeTAP(&out, []testFile{testResults(t, "a_test.rof", testSource), testResults(t, "b_test.rof", `test "b" {}`)})
+	want := `TAP version 13
+ok 1 - a_test.rof: passes
+not ok 2 - a_test.rof: fails
+  ---
+  message: "line #6: Expected 1 but got 2."
+  at: "a_test.rof:4"
+  output: "out\n"
+  ...
+not ok 3 - a_test.rof: errors
+  ---
+  message: "line #9 at '-': 'Operand must be number'"
+  at: "a_test.rof:8"
+  ...
+ok 4 - b_test.rof: b
+1..4
+`
+	if got := out.String(); got != want {
+		t.Errorf("TAP\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeJUnit(&out, []testFile{testResults(t, "a_test.rof", testSource)}); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" errors="1">
+  <testsuite name="a_test.rof" tests="3" failures="1" errors="1" time="0.003">
+    <testcase name="passes" classname="a_test.rof" time="0.001"></testcase>
+    <testcase name="fails" classname="a_test.rof" time="0.001">
+      <failure message="line #6: Expected 1 but got 2." type="AssertionError">a_test.rof:4: line #6: Expected 1 but got 2.</failure>
+      <system-out>out&#xA;</system-out>
+    </testcase>
+    <testcase name="errors" classname="a_test.rof" time="0.001">
+      <error message="line #9 at &#39;-&#39;: &#39;Operand must be number&#39;" type="RuntimeError">a_test.rof:8: line #9 at &#39;-&#39;: &#39;Operand must be number&#39;</error>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if got := out.String(); got != want {
+		t.Errorf("JUnit\n%s\nwant\n%s", got, want)
+	}
+}
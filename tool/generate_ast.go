@@ -1,15 +1,472 @@
+// generate_ast writes the syntax tree of Rof from the ast.spec file of a
+// directory: the node types, their visitors, position accessors and the
+// deep copy and equality helpers. It is run by 'go generate ./rof'.
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"go/format"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
+const header = "// Code generated by tool/generate_ast.go from ast.spec; DO NOT EDIT.\n\npackage rof\n"
+
+type field struct {
+	// Name is empty for an embedded field
+	Name string
+	Type string
+}
+
+// ref is the name used to access the field
+func (f field) ref() string {
+	if f.Name == "" {
+		return f.Type
+	}
+	return f.Name
+}
+
+type node struct {
+	Kind   string
+	Name   string
+	Doc    []string
+	Fields []field
+}
+
+// kind - Names of the declarations generated for expressions or statements
+type kind struct {
+	Interface string
+	Method    string
+	Visitor   string
+	Suffix    string
+	Line      string
+	Copy      string
+	Equal     string
+}
+
+var kinds = map[string]kind{
+	"expr": {"Expr", "Expression", "ExprVisitor", "Expr", "ExprLine", "CopyExpr", "EqualExpr"},
+	"stmt": {"Stmt", "Statement", "StmtVisitor", "Stmt", "StmtLine", "CopyStmt", "EqualStmt"},
+}
+
+// spanField is added to every node, the parser sets it
+var spanField = field{"Span", "Span"}
+
+var fieldTypes = map[string]bool{
+	"Token": true, "*Token": true, "[]Token": true,
+	"Expr": true, "[]Expr": true,
+	"Stmt": true, "[]Stmt": true,
+	"interface{}": true,
+}
+
 func main() {
-	if len(os.Args) > 2 {
+	if len(os.Args) != 2 {
 		fmt.Println("Usage: generate_ast <output directory>")
-		return
+		os.Exit(64)
 	}
 	outputDir := os.Args[1]
 
+	nodes, err := readSpec(filepath.Join(outputDir, "ast.spec"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "generate_ast:", err)
+		os.Exit(1)
+	}
+
+	files := map[string]func(*generator, []node){
+		"expression.go": func(g *generator, nodes []node) { g.nodes("expr", nodes) },
+		"statement.go":  func(g *generator, nodes []node) { g.nodes("stmt", nodes) },
+		"visitor.go":    (*generator).visitors,
+		"position.go":   (*generator).positions,
+		"ast_copy.go":   (*generator).copies,
+		"ast_equal.go":  (*generator).equals,
+	}
+	for name, generate := range files {
+		g := &generator{}
+		g.p(header)
+		generate(g, nodes)
+		source, err := format.Source(g.buf.Bytes())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "generate_ast: %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		if err := ioutil.WriteFile(filepath.Join(outputDir, name), source, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, "generate_ast:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// readSpec parses the node declarations, see rof/ast.spec for the format
+func readSpec(path string) ([]node, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var nodes []node
+	var doc []string
+	seen := map[string]bool{}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			doc = nil
+			continue
+		case strings.HasPrefix(line, "//"):
+			doc = append(doc, line)
+			continue
+		}
+
+		colon := strings.Index(line, ":")
+		words := strings.Fields(line[:max(colon, 0)])
+		if colon < 0 || len(words) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected 'expr|stmt Name: fields'", path, n)
+		}
+		if _, ok := kinds[words[0]]; !ok {
+			return nil, fmt.Errorf("%s:%d: unknown node kind %q", path, n, words[0])
+		}
+		if seen[words[1]] {
+			return nil, fmt.Errorf("%s:%d: %s is declared twice", path, n, words[1])
+		}
+		seen[words[1]] = true
+
+		nd := node{Kind: words[0], Name: words[1], Doc: doc}
+		doc = nil
+		for _, decl := range strings.Split(line[colon+1:], ",") {
+			parts := strings.Fields(decl)
+			var fd field
+			switch len(parts) {
+			case 1:
+				fd = field{Type: parts[0]}
+			case 2:
+				fd = field{Name: parts[0], Type: parts[1]}
+			default:
+				return nil, fmt.Errorf("%s:%d: expected 'Name Type' or 'Type', got %q", path, n, strings.TrimSpace(decl))
+			}
+			if !fieldTypes[fd.Type] {
+				return nil, fmt.Errorf("%s:%d: unknown field type %q", path, n, fd.Type)
+			}
+			if fd.Name == "" && fd.Type != "Expr" && fd.Type != "Stmt" {
+				return nil, fmt.Errorf("%s:%d: only Expr and Stmt can be embedded", path, n)
+			}
+			nd.Fields = append(nd.Fields, fd)
+		}
+		nd.Fields = append(nd.Fields, spanField)
+		nodes = append(nodes, nd)
+	}
+	return nodes, sc.Err()
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+type generator struct {
+	buf bytes.Buffer
+}
+
+func (g *generator) p(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+func receiver(n node) string {
+	return strings.ToLower(n.Name[:1])
+}
+
+func (g *generator) nodes(k string, nodes []node) {
+	kd := kinds[k]
+	g.p("type %s interface {", kd.Interface)
+	g.p("%s() %s", kd.Method, kd.Interface)
+	g.p("Accept(visitor %s) interface{}", kd.Visitor)
+	g.p("}")
+	for _, n := range nodes {
+		if n.Kind != k {
+			continue
+		}
+		r := receiver(n)
+		g.p("")
+		for _, line := range n.Doc {
+			g.p("%s", line)
+		}
+		g.p("type %s struct {", n.Name)
+		for _, f := range n.Fields {
+			g.p("%s %s", f.Name, f.Type)
+		}
+		g.p("}")
+		g.p("")
+		g.p("func (%s %s) %s() %s {", r, n.Name, kd.Method, kd.Interface)
+		g.p("return %s", r)
+		g.p("}")
+		g.p("")
+		g.p("func (%s %s) Accept(visitor %s) interface{} {", r, n.Name, kd.Visitor)
+		g.p("return visitor.Visit%s%s(%s)", n.Name, kd.Suffix, r)
+		g.p("}")
+	}
+}
+
+func (g *generator) visitors(nodes []node) {
+	for _, k := range []string{"expr", "stmt"} {
+		kd := kinds[k]
+		g.p("")
+		g.p("// %s - Operation over every kind of %s, called by Accept", kd.Visitor, kd.Interface)
+		g.p("type %s interface {", kd.Visitor)
+		for _, n := range nodes {
+			if n.Kind == k {
+				g.p("Visit%s%s(%s %s) interface{}", n.Name, kd.Suffix, k, n.Name)
+			}
+		}
+		g.p("}")
+	}
+}
+
+func (g *generator) positions(nodes []node) {
+	g.p("")
+	g.p("// StmtLine returns the line of the first token in a statement, 0 when")
+	g.p("// the statement has no token like an empty block")
+	g.lineFunc("stmt", nodes)
+	g.p("")
+	g.p("// ExprLine returns the line of the first token in an expression, 0")
+	g.p("// when it has none like a literal")
+	g.lineFunc("expr", nodes)
+	for _, k := range []string{"stmt", "expr"} {
+		kd := kinds[k]
+		g.p("")
+		g.p("// %sSpan returns the source range of %s", kd.Interface, k)
+		g.p("func %sSpan(%s %s) Span {", kd.Interface, k, kd.Interface)
+		g.p("switch t := %s.(type) {", k)
+		for _, n := range nodes {
+			if n.Kind == k {
+				g.p("case %s:", n.Name)
+				g.p("return t.Span")
+			}
+		}
+		g.p("}")
+		g.p("return Span{}")
+		g.p("}")
+	}
+}
+
+func (g *generator) lineFunc(k string, nodes []node) {
+	kd := kinds[k]
+	g.p("func %s(%s %s) int {", kd.Line, k, kd.Interface)
+	g.p("switch t := %s.(type) {", k)
+	for _, n := range nodes {
+		if n.Kind != k {
+			continue
+		}
+		g.p("case %s:", n.Name)
+		for _, f := range n.Fields {
+			g.fieldLine("t."+f.ref(), f.Type)
+		}
+	}
+	g.p("}")
+	g.p("return 0")
+	g.p("}")
+}
+
+// fieldLine returns the line of a field when it has one
+func (g *generator) fieldLine(ref, typ string) {
+	switch typ {
+	case "Token":
+		g.p("if %s.Line != 0 {", ref)
+		g.p("return %s.Line", ref)
+		g.p("}")
+	case "*Token":
+		g.p("if %s != nil && %s.Line != 0 {", ref, ref)
+		g.p("return %s.Line", ref)
+		g.p("}")
+	case "Expr", "Stmt":
+		g.p("if line := %s(%s); line != 0 {", kinds[strings.ToLower(typ)].Line, ref)
+		g.p("return line")
+		g.p("}")
+	case "[]Token", "[]Expr", "[]Stmt":
+		g.p("for _, e := range %s {", ref)
+		g.fieldLine("e", typ[2:])
+		g.p("}")
+	}
+}
+
+func (g *generator) copies(nodes []node) {
+	for _, k := range []string{"expr", "stmt"} {
+		kd := kinds[k]
+		g.p("")
+		g.p("// %s returns a deep copy of %s, tokens are copied by value", kd.Copy, k)
+		g.p("func %s(%s %s) %s {", kd.Copy, k, kd.Interface, kd.Interface)
+		g.p("switch t := %s.(type) {", k)
+		for _, n := range nodes {
+			if n.Kind != k {
+				continue
+			}
+			var values []string
+			for _, f := range n.Fields {
+				values = append(values, f.ref()+": "+copyValue("t."+f.ref(), f.Type))
+			}
+			g.p("case %s:", n.Name)
+			g.p("return %s{%s}", n.Name, strings.Join(values, ", "))
+		}
+		g.p("}")
+		g.p("return nil")
+		g.p("}")
+	}
+	g.p(`
+func copyTokenPtr(t *Token) *Token {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func copyTokens(tokens []Token) []Token {
+	if tokens == nil {
+		return nil
+	}
+	return append([]Token{}, tokens...)
+}
+
+func copyExprs(exprs []Expr) []Expr {
+	if exprs == nil {
+		return nil
+	}
+	c := make([]Expr, len(exprs))
+	for n, e := range exprs {
+		c[n] = CopyExpr(e)
+	}
+	return c
+}
+
+func copyStmts(stmts []Stmt) []Stmt {
+	if stmts == nil {
+		return nil
+	}
+	c := make([]Stmt, len(stmts))
+	for n, s := range stmts {
+		c[n] = CopyStmt(s)
+	}
+	return c
+}`)
+}
+
+func copyValue(ref, typ string) string {
+	switch typ {
+	case "*Token":
+		return "copyTokenPtr(" + ref + ")"
+	case "[]Token":
+		return "copyTokens(" + ref + ")"
+	case "Expr":
+		return "CopyExpr(" + ref + ")"
+	case "[]Expr":
+		return "copyExprs(" + ref + ")"
+	case "Stmt":
+		return "CopyStmt(" + ref + ")"
+	case "[]Stmt":
+		return "copyStmts(" + ref + ")"
+	}
+	// Tokens and literal values
+	return ref
+}
+
+func (g *generator) equals(nodes []node) {
+	for _, k := range []string{"expr", "stmt"} {
+		kd := kinds[k]
+		g.p("")
+		g.p("// %s reports whether a and b have the same shape and tokens,", kd.Equal)
+		g.p("// the positions and comments of the tokens are ignored")
+		g.p("func %s(a, b %s) bool {", kd.Equal, kd.Interface)
+		g.p("switch x := a.(type) {")
+		for _, n := range nodes {
+			if n.Kind != k {
+				continue
+			}
+			tests := []string{"ok"}
+			for _, f := range n.Fields {
+				if f != spanField {
+					tests = append(tests, equalTest("x."+f.ref(), "y."+f.ref(), f.Type))
+				}
+			}
+			g.p("case %s:", n.Name)
+			g.p("y, ok := b.(%s)", n.Name)
+			g.p("return %s", strings.Join(tests, " && "))
+		}
+		g.p("}")
+		g.p("return a == nil && b == nil")
+		g.p("}")
+	}
+	g.p(`
+func equalToken(a, b Token) bool {
+	return a.TokenType == b.TokenType && a.Lexeme == b.Lexeme && a.Literal == b.Literal
+}
+
+func equalTokenPtr(a, b *Token) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return equalToken(*a, *b)
+}
+
+func equalTokens(a, b []Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if !equalToken(a[n], b[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalExprs(a, b []Expr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if !EqualExpr(a[n], b[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStmts(a, b []Stmt) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if !EqualStmt(a[n], b[n]) {
+			return false
+		}
+	}
+	return true
+}`)
+}
+
+func equalTest(a, b, typ string) string {
+	switch typ {
+	case "Token":
+		return "equalToken(" + a + ", " + b + ")"
+	case "*Token":
+		return "equalTokenPtr(" + a + ", " + b + ")"
+	case "[]Token":
+		return "equalTokens(" + a + ", " + b + ")"
+	case "Expr":
+		return "EqualExpr(" + a + ", " + b + ")"
+	case "[]Expr":
+		return "equalExprs(" + a + ", " + b + ")"
+	case "Stmt":
+		return "EqualStmt(" + a + ", " + b + ")"
+	case "[]Stmt":
+		return "equalStmts(" + a + ", " + b + ")"
+	}
+	return a + " == " + b
 }